@@ -4,60 +4,187 @@
 package utils
 
 import (
+	"context"
+	"math"
+	"math/rand"
 	"time"
+
+	"github.com/juju/utils/clock"
 )
 
 // The Attempt and AttemptStrategy types are copied from those in launchpad.net/goamz/aws.
 
 // AttemptStrategy represents a strategy for waiting for an action
 // to complete successfully.
+//
+// By default attempts are made at a fixed interval; setting
+// Multiplier, MaxDelay and Jitter gives exponential backoff.
 type AttemptStrategy struct {
 	Total time.Duration // total duration of attempt.
 	Delay time.Duration // interval between each try in the burst.
 	Min   int           // minimum number of retries; overrides Total
+
+	// Multiplier, if greater than 1, multiplies the interval
+	// after each try, so the first interval is Delay, the
+	// next Delay*Multiplier, and so on.
+	Multiplier float64
+
+	// MaxDelay, if positive, limits the interval between tries.
+	MaxDelay time.Duration
+
+	// Jitter specifies how the interval is randomized.
+	Jitter Jitter
+
+	// MaxAttempts, if positive, limits the number of
+	// tries; it overrides Min and Total.
+	MaxAttempts int
+
+	// Clock is used to measure and wait for intervals.
+	// If it is nil, clock.WallClock is used.
+	Clock clock.Clock
+
+	// Stop, if non-nil, stops the attempts when it is closed,
+	// interrupting any wait for the next try.
+	Stop <-chan struct{}
 }
 
+// Jitter specifies how the interval between tries
+// of an AttemptStrategy is randomized.
+type Jitter int
+
+const (
+	// NoJitter uses the interval unchanged.
+	NoJitter Jitter = iota
+
+	// FullJitter chooses an interval between
+	// zero and the computed interval.
+	FullJitter
+
+	// EqualJitter chooses an interval between half
+	// the computed interval and the computed interval.
+	EqualJitter
+)
+
 type Attempt struct {
 	strategy AttemptStrategy
+	clock    clock.Clock
+	done     <-chan struct{}
 	last     time.Time
 	end      time.Time
+	delay    time.Duration
 	force    bool
+	stopped  bool
 	count    int
 }
 
 // Start begins a new sequence of attempts for the given strategy.
 func (s AttemptStrategy) Start() *Attempt {
-	now := time.Now()
+	return s.StartContext(context.Background())
+}
+
+// StartContext is like Start except that the attempts
+// are also stopped when the context is done.
+func (s AttemptStrategy) StartContext(ctx context.Context) *Attempt {
+	clk := s.Clock
+	if clk == nil {
+		clk = clock.WallClock
+	}
+	now := clk.Now()
 	return &Attempt{
 		strategy: s,
+		clock:    clk,
+		done:     ctx.Done(),
 		last:     now,
 		end:      now.Add(s.Total),
+		delay:    s.Delay,
 		force:    true,
 	}
 }
 
+// delay returns the interval to wait after the given
+// number of tries before making the next one.
+func (s AttemptStrategy) delay(count int) time.Duration {
+	d := float64(s.Delay)
+	if s.Multiplier > 1 {
+		for i := 1; i < count; i++ {
+			d *= s.Multiplier
+			if s.MaxDelay > 0 && d > float64(s.MaxDelay) {
+				break
+			}
+		}
+	}
+	if s.MaxDelay > 0 && d > float64(s.MaxDelay) {
+		d = float64(s.MaxDelay)
+	}
+	if d > math.MaxInt64 {
+		d = math.MaxInt64
+	}
+	switch s.Jitter {
+	case FullJitter:
+		d = rand.Float64() * d
+	case EqualJitter:
+		d = d/2 + rand.Float64()*d/2
+	}
+	return time.Duration(d)
+}
+
 // Next waits until it is time to perform the next attempt or returns
 // false if it is time to stop trying.
 // It always returns true the first time it is called - we are guaranteed to
-// make at least one attempt.
+// make at least one attempt - unless the attempts have been stopped.
 func (a *Attempt) Next() bool {
-	now := time.Now()
+	if a.isStopped() {
+		return false
+	}
+	now := a.clock.Now()
 	sleep := a.nextSleep(now)
-	if !a.force && !now.Add(sleep).Before(a.end) && a.strategy.Min <= a.count {
+	if !a.force && !a.more(now, sleep) {
 		return false
 	}
 	a.force = false
 	if sleep > 0 && a.count > 0 {
-		time.Sleep(sleep)
-		now = time.Now()
+		select {
+		case <-a.clock.After(sleep):
+		case <-a.strategy.Stop:
+			a.stopped = true
+			return false
+		case <-a.done:
+			a.stopped = true
+			return false
+		}
+		now = a.clock.Now()
 	}
 	a.count++
 	a.last = now
+	a.delay = a.strategy.delay(a.count)
 	return true
 }
 
+// more reports whether the strategy allows another
+// try after sleeping for the given duration.
+func (a *Attempt) more(now time.Time, sleep time.Duration) bool {
+	if max := a.strategy.MaxAttempts; max > 0 {
+		return a.count < max
+	}
+	return now.Add(sleep).Before(a.end) || a.strategy.Min > a.count
+}
+
+// isStopped reports whether the attempts have been stopped.
+func (a *Attempt) isStopped() bool {
+	if !a.stopped {
+		select {
+		case <-a.strategy.Stop:
+			a.stopped = true
+		case <-a.done:
+			a.stopped = true
+		default:
+		}
+	}
+	return a.stopped
+}
+
 func (a *Attempt) nextSleep(now time.Time) time.Duration {
-	sleep := a.strategy.Delay - now.Sub(a.last)
+	sleep := a.delay - now.Sub(a.last)
 	if sleep < 0 {
 		return 0
 	}
@@ -66,15 +193,30 @@ func (a *Attempt) nextSleep(now time.Time) time.Duration {
 
 // HasNext returns whether another attempt will be made if the current
 // one fails. If it returns true, the following call to Next is
-// guaranteed to return true.
+// guaranteed to return true unless the attempts are stopped while it
+// is waiting.
 func (a *Attempt) HasNext() bool {
-	if a.force || a.strategy.Min > a.count {
+	if a.isStopped() {
+		return false
+	}
+	if a.force {
 		return true
 	}
-	now := time.Now()
-	if now.Add(a.nextSleep(now)).Before(a.end) {
+	now := a.clock.Now()
+	if a.more(now, a.nextSleep(now)) {
 		a.force = true
 		return true
 	}
 	return false
 }
+
+// Stopped reports whether the attempts were stopped
+// by the strategy's Stop channel or context.
+func (a *Attempt) Stopped() bool {
+	return a.stopped
+}
+
+// Count returns the number of tries that have been made.
+func (a *Attempt) Count() int {
+	return a.count
+}
@@ -4,11 +4,14 @@
 package utils_test
 
 import (
+	"context"
 	"time"
 
+	jc "github.com/juju/testing/checkers"
 	gc "gopkg.in/check.v1"
 
 	"github.com/juju/utils"
+	"github.com/juju/utils/clock/testclock"
 )
 
 func doSomething() (int, error) { return 0, nil }
@@ -90,3 +93,124 @@ func (*utilsSuite) TestAttemptNextHasNext(c *gc.C) {
 	c.Assert(a.HasNext(), gc.Equals, false)
 	c.Assert(a.Next(), gc.Equals, false)
 }
+
+// autoClock is a clock that advances itself
+// by the requested duration when After is called.
+type autoClock struct {
+	*testclock.Clock
+}
+
+func (c autoClock) After(d time.Duration) <-chan time.Time {
+	c.Advance(d)
+	ch := make(chan time.Time, 1)
+	ch <- c.Now()
+	return ch
+}
+
+// attemptTimes runs the given attempt strategy with a clock that
+// advances whenever the attempt waits, and returns the time of
+// each try relative to the start.
+func attemptTimes(c *gc.C, s utils.AttemptStrategy) []time.Duration {
+	t0 := time.Time{}
+	clock := autoClock{testclock.NewClock(t0)}
+	s.Clock = clock
+	var got []time.Duration
+	for a := s.Start(); a.Next(); {
+		got = append(got, clock.Now().Sub(t0))
+	}
+	return got
+}
+
+func (*utilsSuite) TestAttemptBackoff(c *gc.C) {
+	got := attemptTimes(c, utils.AttemptStrategy{
+		Delay:       time.Second,
+		Multiplier:  2,
+		MaxDelay:    5 * time.Second,
+		MaxAttempts: 6,
+	})
+	c.Assert(got, jc.DeepEquals, []time.Duration{
+		0,
+		time.Second,
+		3 * time.Second,
+		7 * time.Second,
+		12 * time.Second,
+		17 * time.Second,
+	})
+}
+
+func (*utilsSuite) TestAttemptMaxAttemptsOverridesMinAndTotal(c *gc.C) {
+	got := attemptTimes(c, utils.AttemptStrategy{
+		Total:       time.Minute,
+		Delay:       time.Second,
+		Min:         10,
+		MaxAttempts: 2,
+	})
+	c.Assert(got, jc.DeepEquals, []time.Duration{0, time.Second})
+}
+
+func (*utilsSuite) TestAttemptJitter(c *gc.C) {
+	for _, test := range []struct {
+		jitter utils.Jitter
+		min    time.Duration
+	}{{
+		jitter: utils.FullJitter,
+		min:    0,
+	}, {
+		jitter: utils.EqualJitter,
+		min:    4 * time.Second,
+	}} {
+		got := attemptTimes(c, utils.AttemptStrategy{
+			Delay:       8 * time.Second,
+			Jitter:      test.jitter,
+			MaxAttempts: 20,
+		})
+		c.Assert(got, gc.HasLen, 20)
+		for i := 1; i < len(got); i++ {
+			d := got[i] - got[i-1]
+			c.Check(d >= test.min && d <= 8*time.Second, jc.IsTrue, gc.Commentf("interval %v", d))
+		}
+	}
+}
+
+func (*utilsSuite) TestAttemptStop(c *gc.C) {
+	clock := testclock.NewClock(time.Time{})
+	stop := make(chan struct{})
+	a := utils.AttemptStrategy{
+		Total: time.Hour,
+		Delay: time.Minute,
+		Clock: clock,
+		Stop:  stop,
+	}.Start()
+	c.Assert(a.Next(), jc.IsTrue)
+	c.Assert(a.HasNext(), jc.IsTrue)
+	result := make(chan bool)
+	go func() {
+		result <- a.Next()
+	}()
+	select {
+	case <-clock.Notify():
+	case <-time.After(longWait):
+		c.Fatalf("timed out waiting for attempt to wait")
+	}
+	close(stop)
+	select {
+	case ok := <-result:
+		c.Assert(ok, jc.IsFalse)
+	case <-time.After(longWait):
+		c.Fatalf("timed out waiting for attempt to stop")
+	}
+	c.Assert(a.Stopped(), jc.IsTrue)
+	c.Assert(a.Count(), gc.Equals, 1)
+	c.Assert(a.HasNext(), jc.IsFalse)
+	c.Assert(a.Next(), jc.IsFalse)
+}
+
+func (*utilsSuite) TestAttemptStartContext(c *gc.C) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	a := utils.AttemptStrategy{Total: time.Hour}.StartContext(ctx)
+	c.Assert(a.HasNext(), jc.IsFalse)
+	c.Assert(a.Next(), jc.IsFalse)
+	c.Assert(a.Stopped(), jc.IsTrue)
+	c.Assert(a.Count(), gc.Equals, 0)
+}
@@ -3,31 +3,109 @@
 
 // Package cache provides a simple caching mechanism
 // that limits the age of cache entries and tries to avoid large
-// repopulation events by staggering refresh times.
+// repopulation events by staggering refresh times. A cache
+// may also be bounded in size, in which case the least
+// recently used entries are evicted to make room for new ones.
 package cache
 
 import (
+	"container/list"
 	"math/rand"
 	"sync"
 	"time"
 
 	"gopkg.in/errgo.v1"
+
+	"github.com/juju/utils/clock"
 )
 
 // entry holds a cache entry. The expire field
 // holds the time after which the entry will be
 // considered invalid.
 type entry struct {
+	key    Key
 	value  interface{}
 	expire time.Time
+
+	// err holds the error returned by the fetch
+	// when a failed fetch is cached.
+	err error
+
+	// refresh holds the time after which the entry
+	// will be refreshed in the background.
+	refresh time.Time
+
+	// cost holds the cost of the entry as
+	// reported by Config.Cost.
+	cost int64
+
+	// elem holds the entry's element in the
+	// cache's LRU list. It is nil if the cache
+	// is not bounded in size.
+	elem *list.Element
 }
 
 // Key represents a cache key. It must be a comparable type.
 type Key interface{}
 
+// Config holds the configuration for a Cache.
+type Config struct {
+	// MaxAge holds the maximum age of a cache entry.
+	MaxAge time.Duration
+
+	// MaxEntries holds the maximum number of entries
+	// held in the cache. If it is zero, the number of
+	// entries is not limited.
+	MaxEntries int
+
+	// MaxCost holds the maximum total cost of the entries
+	// held in the cache, as reported by Cost. If it is zero,
+	// the total cost is not limited. A value whose cost is
+	// greater than MaxCost is returned to the caller but not
+	// stored, and nothing is evicted to make room for it.
+	MaxCost int64
+
+	// Cost returns the cost of holding the given value in the
+	// cache, for example its size in bytes. If it is nil,
+	// every entry has a cost of 1.
+	Cost func(key Key, value interface{}) int64
+
+	// ErrorMaxAge holds the maximum age of a cached fetch
+	// error. If it is zero, errors are not cached and every
+	// Get for a key whose fetch fails will fetch it again.
+	// It is usually set to less than MaxAge so that failures
+	// are retried sooner than successful values are refreshed.
+	ErrorMaxAge time.Duration
+
+	// RefreshAge, if non-zero, holds the age after which
+	// an entry is considered stale. A Get for a stale entry
+	// returns the cached value immediately and starts a single
+	// background fetch to refresh it; only Gets for entries
+	// older than MaxAge wait for a fetch. Refresh times are
+	// staggered in the same way as expiry times. RefreshAge
+	// should be less than MaxAge.
+	RefreshAge time.Duration
+
+	// Clock is used to determine the current time.
+	// If it is nil, clock.WallClock will be used.
+	Clock clock.Clock
+
+	// Observer, if non-nil, is notified of
+	// cache hits, misses, fetches and evictions.
+	Observer Observer
+
+	// OnEvict, if non-nil, is called for each entry that is
+	// evicted to keep the cache within MaxEntries or MaxCost.
+	// It is called without the cache's lock held, so it may
+	// safely call methods on the cache.
+	OnEvict func(key Key, value interface{})
+}
+
 // Cache holds a time-limited set of values for arbitrary keys.
 type Cache struct {
 	maxAge time.Duration
+	config Config
+	clock  clock.Clock
 
 	// mu guards the fields below it.
 	mu sync.Mutex
@@ -39,12 +117,52 @@ type Cache struct {
 	// items in the cache when the cache needs to be refreshed.
 	// Instead, we move items from old to new when they're accessed
 	// and throw away the old map at refresh time.
-	old, new map[Key]entry
+	old, new map[Key]*entry
+
+	// lru holds all the entries in old and new, most recently
+	// used first. It is nil if the cache is not bounded in size.
+	lru *list.List
+
+	// cost holds the total cost of the entries in lru.
+	cost int64
+
+	// inFlight holds the fetches currently in progress.
+	inFlight map[Key]*fetchCall
+
+	// stats holds the cache statistics, excluding Len
+	// and AverageFetchLatency, which are calculated
+	// when they are requested.
+	stats Stats
+
+	// fetchTime holds the total time spent in
+	// completed fetches.
+	fetchTime time.Duration
+}
+
+// fetchCall represents a fetch in progress. The value and err
+// fields may be read only after the done channel is closed.
+type fetchCall struct {
+	done  chan struct{}
+	value interface{}
+	err   error
 }
 
+// errFetchPanicked is returned to callers waiting for
+// a fetch that panicked.
+var errFetchPanicked = errgo.New("cache fetch panicked")
+
 // New returns a new Cache that will cache items for
 // at most maxAge.
 func New(maxAge time.Duration) *Cache {
+	return NewWithConfig(Config{
+		MaxAge: maxAge,
+	})
+}
+
+// NewWithConfig returns a new Cache configured
+// according to the given configuration.
+func NewWithConfig(config Config) *Cache {
+	maxAge := config.MaxAge
 	// A maxAge is < 2ns then the expiry code will panic because the
 	// actual expiry time will be maxAge - a random value in the
 	// interval [0, maxAge/2). If maxAge is < 2ns then this requires
@@ -55,9 +173,18 @@ func New(maxAge time.Duration) *Cache {
 	// The returned cache will have a zero-valued expire
 	// time, so will expire immediately, causing the new
 	// map to be created.
-	return &Cache{
+	c := &Cache{
 		maxAge: maxAge,
+		config: config,
+		clock:  config.Clock,
+	}
+	if c.clock == nil {
+		c.clock = clock.WallClock
 	}
+	if config.MaxEntries > 0 || config.MaxCost > 0 {
+		c.lru = list.New()
+	}
+	return c
 }
 
 // Len returns the total number of cached entries.
@@ -71,68 +198,269 @@ func (c *Cache) Len() int {
 func (c *Cache) Evict(key Key) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	delete(c.new, key)
-	delete(c.old, key)
+	c.remove(key)
 }
 
 // EvictAll removes all entries from the cache.
 func (c *Cache) EvictAll() {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	c.new = make(map[Key]entry)
+	c.new = make(map[Key]*entry)
 	c.old = nil
+	if c.lru != nil {
+		c.lru.Init()
+		c.cost = 0
+	}
 }
 
 // Get returns the value for the given key, using fetch to fetch
 // the value if it is not found in the cache.
 // If fetch returns an error, the returned error from Get will have
 // the same cause.
+//
+// If several goroutines call Get concurrently for the same key,
+// only the first calls fetch; the others wait for it to complete
+// and return the same value or error.
+//
+// If the cached entry is stale (see Config.RefreshAge), Get
+// returns it immediately and fetch is called in the background.
 func (c *Cache) Get(key Key, fetch func() (interface{}, error)) (interface{}, error) {
-	return c.getAtTime(key, fetch, time.Now())
+	return c.getAtTime(key, fetch, c.clock.Now())
 }
 
 // getAtTime is the internal version of Get, useful for testing; now represents the current
 // time.
 func (c *Cache) getAtTime(key Key, fetch func() (interface{}, error), now time.Time) (interface{}, error) {
-	if val, ok := c.cachedValue(key, now); ok {
-		return val, nil
+	c.mu.Lock()
+	generation := c.stats.Generations
+	e, ok := c.cachedEntry(key, now)
+	refreshed := c.stats.Generations != generation
+	if ok {
+		c.stats.Hits++
+		if c.isStale(e, now) {
+			if _, ok := c.inFlight[key]; !ok {
+				// Refresh the entry in the background, but
+				// return the stale value without waiting.
+				call := c.startFetch(key)
+				go c.fetch(key, call, fetch, now, true)
+			}
+		}
+		c.mu.Unlock()
+		c.notifyGet(key, true, refreshed)
+		return e.value, e.err
+	}
+	c.stats.Misses++
+	if call, ok := c.inFlight[key]; ok {
+		// Another Get is already fetching the value,
+		// so wait for it rather than fetching it again.
+		c.mu.Unlock()
+		c.notifyGet(key, false, refreshed)
+		<-call.done
+		return call.value, call.err
 	}
+	call := c.startFetch(key)
+	c.mu.Unlock()
+	c.notifyGet(key, false, refreshed)
+	return c.fetch(key, call, fetch, now, false)
+}
+
+// isStale reports whether the given entry should be
+// refreshed in the background.
+// It must be called with c.mu held.
+func (c *Cache) isStale(e *entry, now time.Time) bool {
+	return c.config.RefreshAge > 0 && e.err == nil && now.After(e.refresh)
+}
+
+// startFetch records that a fetch of the given key is in progress
+// and returns the call that will hold its result.
+// It must be called with c.mu held.
+func (c *Cache) startFetch(key Key) *fetchCall {
+	call := &fetchCall{
+		done: make(chan struct{}),
+		err:  errFetchPanicked,
+	}
+	if c.inFlight == nil {
+		c.inFlight = make(map[Key]*fetchCall)
+	}
+	c.inFlight[key] = call
+	return call
+}
+
+// fetch calls the given fetch function, stores its result
+// in the cache and in the given call, and returns it.
+// If refresh is true, the fetch is refreshing a stale entry;
+// an error does not replace the existing value, so the stale
+// value continues to be used until it expires.
+// It must be called without c.mu held.
+func (c *Cache) fetch(key Key, call *fetchCall, fetch func() (interface{}, error), now time.Time, refresh bool) (interface{}, error) {
+	defer func() {
+		c.mu.Lock()
+		delete(c.inFlight, key)
+		c.mu.Unlock()
+		close(call.done)
+	}()
 	// Fetch the data without the mutex held
 	// so that one slow fetch doesn't hold up
 	// all the other cache accesses.
+	start := c.clock.Now()
 	val, err := fetch()
+	c.recordFetch(key, c.clock.Now().Sub(start), err)
 	if err != nil {
-		// TODO consider caching cache misses.
-		return nil, errgo.Mask(err, errgo.Any)
+		call.value, call.err = nil, errgo.Mask(err, errgo.Any)
+		if c.config.ErrorMaxAge > 0 && !refresh {
+			c.store(&entry{
+				key:  key,
+				err:  call.err,
+				cost: 1,
+			}, c.config.ErrorMaxAge, now)
+		}
+		return call.value, call.err
+	}
+	call.value, call.err = val, nil
+	e := &entry{
+		key:   key,
+		value: val,
+		cost:  1,
+	}
+	if c.lru != nil && c.config.Cost != nil {
+		// Calculate the cost without the mutex held
+		// because we don't know how long it will take.
+		e.cost = c.config.Cost(key, val)
+	}
+	c.store(e, c.maxAge, now)
+	return val, nil
+}
+
+// store adds the given entry to the cache with an expiry
+// time no later than now+maxAge.
+func (c *Cache) store(e *entry, maxAge time.Duration, now time.Time) {
+	if maxAge < 2*time.Nanosecond {
+		maxAge = 2 * time.Nanosecond
 	}
 	c.mu.Lock()
-	defer c.mu.Unlock()
 	// Add the new cache entry. Because it's quite likely that a
 	// large number of cache entries will be initially fetched at
 	// the same time, we want to avoid a thundering herd of fetches
 	// when they all expire at the same time, so we set the expiry
-	// time to a random interval between [now + t.maxAge/2, now +
-	// t.maxAge] and so they'll be spread over time without
+	// time to a random interval between [now + maxAge/2, now +
+	// maxAge] and so they'll be spread over time without
 	// compromising the maxAge value.
-	c.new[key] = entry{
-		value:  val,
-		expire: now.Add(c.maxAge - time.Duration(rand.Int63n(int64(c.maxAge/2)))),
+	age := maxAge - time.Duration(rand.Int63n(int64(maxAge/2)))
+	e.expire = now.Add(age)
+	if c.config.RefreshAge > 0 {
+		// Stagger the refresh times in proportion
+		// to the expiry times.
+		scale := float64(age) / float64(maxAge)
+		e.refresh = now.Add(time.Duration(float64(c.config.RefreshAge) * scale))
 	}
-	return val, nil
+	evicted := c.add(e)
+	c.mu.Unlock()
+	c.notifyEvicted(evicted)
+}
+
+// add adds the given entry to the new map, replacing any
+// existing entry with the same key. If the cache is bounded
+// in size, it returns any entries evicted to make room for it.
+// It must be called with c.mu held.
+func (c *Cache) add(e *entry) []*entry {
+	// Another Get may have added an entry for the same key
+	// while we were fetching, so remove it first to keep
+	// the LRU list consistent.
+	c.remove(e.key)
+	if c.lru != nil && c.config.MaxCost > 0 && e.cost > c.config.MaxCost {
+		// The entry could never fit, so don't
+		// evict everything else trying to make
+		// room for it.
+		return nil
+	}
+	c.new[e.key] = e
+	if c.lru == nil {
+		return nil
+	}
+	e.elem = c.lru.PushFront(e)
+	c.cost += e.cost
+	var evicted []*entry
+	for c.overLimit() {
+		oldest := c.lru.Back().Value.(*entry)
+		c.remove(oldest.key)
+		c.stats.Evictions++
+		evicted = append(evicted, oldest)
+	}
+	return evicted
+}
+
+// overLimit reports whether the cache holds more entries
+// than it is configured to allow.
+// It must be called with c.mu held.
+func (c *Cache) overLimit() bool {
+	if c.lru.Len() == 0 {
+		return false
+	}
+	if c.config.MaxEntries > 0 && c.lru.Len() > c.config.MaxEntries {
+		return true
+	}
+	return c.config.MaxCost > 0 && c.cost > c.config.MaxCost
+}
+
+// remove removes any entry with the given key from the cache.
+// It must be called with c.mu held.
+func (c *Cache) remove(key Key) {
+	if e, ok := c.new[key]; ok {
+		delete(c.new, key)
+		c.unlink(e)
+	}
+	if e, ok := c.old[key]; ok {
+		delete(c.old, key)
+		c.unlink(e)
+	}
+}
+
+// unlink removes the given entry from the LRU list
+// if the cache is bounded in size.
+// It must be called with c.mu held.
+func (c *Cache) unlink(e *entry) {
+	if e.elem == nil {
+		return
+	}
+	c.lru.Remove(e.elem)
+	c.cost -= e.cost
+	e.elem = nil
 }
 
-// cachedValue returns any cached value for the given key
+// notifyEvicted calls the OnEvict callback and
+// notifies the observer, if any, for all the given entries.
+// It must be called without c.mu held.
+func (c *Cache) notifyEvicted(evicted []*entry) {
+	for _, e := range evicted {
+		if c.config.OnEvict != nil {
+			c.config.OnEvict(e.key, e.value)
+		}
+		if c.config.Observer != nil {
+			c.config.Observer.Evicted(e.key)
+		}
+	}
+}
+
+// cachedEntry returns any cached entry for the given key
 // and whether it was found.
-func (c *Cache) cachedValue(key Key, now time.Time) (interface{}, bool) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+// It must be called with c.mu held.
+func (c *Cache) cachedEntry(key Key, now time.Time) (*entry, bool) {
 	if now.After(c.expire) {
+		if c.lru != nil {
+			// The entries in the old map are about to be
+			// discarded, so remove them from the LRU list too.
+			for _, e := range c.old {
+				c.unlink(e)
+			}
+		}
 		c.old = c.new
-		c.new = make(map[Key]entry)
+		c.new = make(map[Key]*entry)
 		c.expire = now.Add(c.maxAge)
+		c.stats.Generations++
 	}
 	if e, ok := c.entry(c.new, key, now); ok {
-		return e.value, true
+		c.touch(e)
+		return e, true
 	}
 	if e, ok := c.entry(c.old, key, now); ok {
 		// An old entry has been accessed; move it to the new
@@ -144,22 +472,32 @@ func (c *Cache) cachedValue(key Key, now time.Time) (interface{}, bool) {
 		// time it is dropped.
 		c.new[key] = e
 		delete(c.old, key)
-		return e.value, true
+		c.touch(e)
+		return e, true
 	}
 	return nil, false
 }
 
+// touch marks the given entry as the most recently used.
+// It must be called with c.mu held.
+func (c *Cache) touch(e *entry) {
+	if e.elem != nil {
+		c.lru.MoveToFront(e.elem)
+	}
+}
+
 // entry returns an entry from the map and whether it
 // was found. If the entry has expired, it is deleted from the map.
-func (c *Cache) entry(m map[Key]entry, key Key, now time.Time) (entry, bool) {
+func (c *Cache) entry(m map[Key]*entry, key Key, now time.Time) (*entry, bool) {
 	e, ok := m[key]
 	if !ok {
-		return entry{}, false
+		return nil, false
 	}
 	if now.After(e.expire) {
 		// Delete expired entries.
 		delete(m, key)
-		return entry{}, false
+		c.unlink(e)
+		return nil, false
 	}
 	return e, true
 }
@@ -11,7 +11,9 @@ import (
 	gc "gopkg.in/check.v1"
 	"gopkg.in/errgo.v1"
 
+	"github.com/juju/utils"
 	"github.com/juju/utils/cache"
+	"github.com/juju/utils/clock/testclock"
 )
 
 type suite struct{}
@@ -241,6 +243,367 @@ func (*suite) TestRefreshSpread(c *gc.C) {
 	c.Assert(total, gc.Equals, N)
 }
 
+func (*suite) TestMaxEntries(c *gc.C) {
+	var evicted []cache.Key
+	p := cache.NewWithConfig(cache.Config{
+		MaxAge:     time.Hour,
+		MaxEntries: 2,
+		OnEvict: func(key cache.Key, value interface{}) {
+			c.Check(value, gc.Equals, key)
+			evicted = append(evicted, key)
+		},
+	})
+	for _, key := range []string{"a", "b"} {
+		v, err := p.Get(key, fetchValue(key))
+		c.Assert(err, gc.IsNil)
+		c.Assert(v, gc.Equals, key)
+	}
+	c.Assert(p.Len(), gc.Equals, 2)
+
+	// Access "a" so that "b" becomes the least recently used.
+	v, err := p.Get("a", fetchError(errUnexpectedFetch))
+	c.Assert(err, gc.IsNil)
+	c.Assert(v, gc.Equals, "a")
+
+	v, err = p.Get("c", fetchValue("c"))
+	c.Assert(err, gc.IsNil)
+	c.Assert(v, gc.Equals, "c")
+	c.Assert(p.Len(), gc.Equals, 2)
+	c.Assert(evicted, gc.DeepEquals, []cache.Key{"b"})
+
+	// "a" and "c" are still cached but "b" must be fetched again.
+	_, err = p.Get("a", fetchError(errUnexpectedFetch))
+	c.Assert(err, gc.IsNil)
+	_, err = p.Get("c", fetchError(errUnexpectedFetch))
+	c.Assert(err, gc.IsNil)
+	v, err = p.Get("b", fetchValue("b"))
+	c.Assert(err, gc.IsNil)
+	c.Assert(v, gc.Equals, "b")
+	c.Assert(evicted, gc.DeepEquals, []cache.Key{"b", "a"})
+}
+
+func (*suite) TestMaxCost(c *gc.C) {
+	var evicted []cache.Key
+	p := cache.NewWithConfig(cache.Config{
+		MaxAge:  time.Hour,
+		MaxCost: 10,
+		Cost: func(key cache.Key, value interface{}) int64 {
+			return int64(len(value.(string)))
+		},
+		OnEvict: func(key cache.Key, value interface{}) {
+			evicted = append(evicted, key)
+		},
+	})
+	_, err := p.Get("a", fetchValue("aaaa"))
+	c.Assert(err, gc.IsNil)
+	_, err = p.Get("b", fetchValue("bbbb"))
+	c.Assert(err, gc.IsNil)
+	c.Assert(evicted, gc.HasLen, 0)
+
+	// Adding "c" brings the total cost up to the limit,
+	// so nothing is evicted until "d" takes it over the
+	// limit, evicting "a".
+	_, err = p.Get("c", fetchValue("cc"))
+	c.Assert(err, gc.IsNil)
+	c.Assert(evicted, gc.HasLen, 0)
+	_, err = p.Get("d", fetchValue("d"))
+	c.Assert(err, gc.IsNil)
+	c.Assert(evicted, gc.DeepEquals, []cache.Key{"a"})
+	c.Assert(p.Len(), gc.Equals, 3)
+
+	// An entry that is larger than the limit is returned
+	// but not stored, and nothing is evicted for it.
+	v, err := p.Get("e", fetchValue("eeeeeeeeeee"))
+	c.Assert(err, gc.IsNil)
+	c.Assert(v, gc.Equals, "eeeeeeeeeee")
+	c.Assert(evicted, gc.DeepEquals, []cache.Key{"a"})
+	c.Assert(p.Len(), gc.Equals, 3)
+	c.Assert(p.Stats().Evictions, gc.Equals, int64(1))
+
+	// It is fetched again next time.
+	v, err = p.Get("e", fetchValue("EEEEEEEEEEE"))
+	c.Assert(err, gc.IsNil)
+	c.Assert(v, gc.Equals, "EEEEEEEEEEE")
+	c.Assert(p.Len(), gc.Equals, 3)
+}
+
+func (*suite) TestMaxEntriesWithEvict(c *gc.C) {
+	p := cache.NewWithConfig(cache.Config{
+		MaxAge:     time.Hour,
+		MaxEntries: 2,
+		OnEvict: func(key cache.Key, value interface{}) {
+			c.Errorf("unexpected eviction of %q", key)
+		},
+	})
+	_, err := p.Get("a", fetchValue("a"))
+	c.Assert(err, gc.IsNil)
+	_, err = p.Get("b", fetchValue("b"))
+	c.Assert(err, gc.IsNil)
+
+	// Explicitly evicted entries make room for new ones.
+	p.Evict("a")
+	_, err = p.Get("c", fetchValue("c"))
+	c.Assert(err, gc.IsNil)
+	c.Assert(p.Len(), gc.Equals, 2)
+
+	p.EvictAll()
+	_, err = p.Get("d", fetchValue("d"))
+	c.Assert(err, gc.IsNil)
+	_, err = p.Get("e", fetchValue("e"))
+	c.Assert(err, gc.IsNil)
+	c.Assert(p.Len(), gc.Equals, 2)
+}
+
+func (*suite) TestMaxEntriesWithRefresh(c *gc.C) {
+	now := time.Now()
+	var evicted []cache.Key
+	p := cache.NewWithConfig(cache.Config{
+		MaxAge:     time.Minute,
+		MaxEntries: 3,
+		OnEvict: func(key cache.Key, value interface{}) {
+			evicted = append(evicted, key)
+		},
+	})
+	_, err := cache.GetAtTime(p, "a", fetchValue("a"), now)
+	c.Assert(err, gc.IsNil)
+	_, err = cache.GetAtTime(p, "b", fetchValue("b"), now)
+	c.Assert(err, gc.IsNil)
+	_, err = cache.GetAtTime(p, "c", fetchValue("c"), now.Add(time.Minute+1))
+	c.Assert(err, gc.IsNil)
+	c.Assert(p.Len(), gc.Equals, 3)
+
+	// After another refresh period "a" and "b" are discarded
+	// without being reported as evicted, and no longer
+	// count towards the limit.
+	_, err = cache.GetAtTime(p, "d", fetchValue("d"), now.Add(2*time.Minute+2))
+	c.Assert(err, gc.IsNil)
+	c.Assert(p.Len(), gc.Equals, 2)
+	_, err = cache.GetAtTime(p, "e", fetchValue("e"), now.Add(2*time.Minute+2))
+	c.Assert(err, gc.IsNil)
+	c.Assert(p.Len(), gc.Equals, 3)
+	c.Assert(evicted, gc.HasLen, 0)
+
+	// The least recently used entry is still evicted
+	// when it is in the old map.
+	_, err = cache.GetAtTime(p, "f", fetchValue("f"), now.Add(2*time.Minute+2))
+	c.Assert(err, gc.IsNil)
+	c.Assert(p.Len(), gc.Equals, 3)
+	c.Assert(evicted, gc.DeepEquals, []cache.Key{"c"})
+}
+
+func (*suite) TestConcurrentGetsShareFetch(c *gc.C) {
+	p := cache.New(time.Hour)
+	v, err := getConcurrently(c, p, "a", 10, func() (interface{}, error) {
+		return "a", nil
+	})
+	c.Assert(err, gc.IsNil)
+	c.Assert(v, gc.Equals, "a")
+
+	v, err = p.Get("a", fetchError(errUnexpectedFetch))
+	c.Assert(err, gc.IsNil)
+	c.Assert(v, gc.Equals, "a")
+}
+
+func (*suite) TestConcurrentGetsShareFetchError(c *gc.C) {
+	p := cache.New(time.Hour)
+	expectErr := errgo.New("hello")
+	v, err := getConcurrently(c, p, "a", 10, fetchError(expectErr))
+	c.Assert(err, gc.ErrorMatches, "hello")
+	c.Assert(errgo.Cause(err), gc.Equals, expectErr)
+	c.Assert(v, gc.IsNil)
+
+	// Errors are not cached by default.
+	v, err = p.Get("a", fetchValue("a"))
+	c.Assert(err, gc.IsNil)
+	c.Assert(v, gc.Equals, "a")
+}
+
+func (*suite) TestErrorMaxAge(c *gc.C) {
+	now := time.Now()
+	p := cache.NewWithConfig(cache.Config{
+		MaxAge:      time.Hour,
+		ErrorMaxAge: time.Minute,
+	})
+	expectErr := errgo.New("hello")
+	_, err := cache.GetAtTime(p, "a", fetchError(expectErr), now)
+	c.Assert(errgo.Cause(err), gc.Equals, expectErr)
+
+	// The error is definitely cached before half the error expiry time.
+	v, err := cache.GetAtTime(p, "a", fetchError(errUnexpectedFetch), now.Add(time.Minute/2-1))
+	c.Assert(err, gc.ErrorMatches, "hello")
+	c.Assert(errgo.Cause(err), gc.Equals, expectErr)
+	c.Assert(v, gc.IsNil)
+
+	// The error is definitely expired after the error expiry time.
+	v, err = cache.GetAtTime(p, "a", fetchValue("a"), now.Add(time.Minute+1))
+	c.Assert(err, gc.IsNil)
+	c.Assert(v, gc.Equals, "a")
+
+	// The successful value is cached for longer.
+	v, err = cache.GetAtTime(p, "a", fetchError(errUnexpectedFetch), now.Add(10*time.Minute))
+	c.Assert(err, gc.IsNil)
+	c.Assert(v, gc.Equals, "a")
+}
+
+func (*suite) TestFetchPanic(c *gc.C) {
+	p := cache.New(time.Hour)
+	c.Assert(func() {
+		p.Get("a", func() (interface{}, error) {
+			panic("boom")
+		})
+	}, gc.PanicMatches, "boom")
+
+	// A later Get is not blocked by the failed fetch.
+	v, err := p.Get("a", fetchValue("a"))
+	c.Assert(err, gc.IsNil)
+	c.Assert(v, gc.Equals, "a")
+}
+
+func (*suite) TestRefreshAge(c *gc.C) {
+	clk := testclock.NewClock(time.Now())
+	p := cache.NewWithConfig(cache.Config{
+		MaxAge:     time.Hour,
+		RefreshAge: 10 * time.Minute,
+		Clock:      clk,
+	})
+	v, err := p.Get("a", fetchValue("a1"))
+	c.Assert(err, gc.IsNil)
+	c.Assert(v, gc.Equals, "a1")
+
+	// The entry is definitely fresh before half the refresh age.
+	clk.Advance(5*time.Minute - 1)
+	v, err = p.Get("a", fetchError(errUnexpectedFetch))
+	c.Assert(err, gc.IsNil)
+	c.Assert(v, gc.Equals, "a1")
+
+	// The entry is definitely stale after the refresh age, so
+	// the stale value is returned while it is refreshed.
+	clk.Advance(5*time.Minute + 2)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	v, err = p.Get("a", func() (interface{}, error) {
+		close(started)
+		<-release
+		return "a2", nil
+	})
+	c.Assert(err, gc.IsNil)
+	c.Assert(v, gc.Equals, "a1")
+	<-started
+
+	// Only one refresh happens at a time.
+	v, err = p.Get("a", fetchError(errUnexpectedFetch))
+	c.Assert(err, gc.IsNil)
+	c.Assert(v, gc.Equals, "a1")
+
+	close(release)
+	waitNotInFlight(c, p, "a")
+	v, err = p.Get("a", fetchError(errUnexpectedFetch))
+	c.Assert(err, gc.IsNil)
+	c.Assert(v, gc.Equals, "a2")
+}
+
+func (*suite) TestRefreshAgeErrorKeepsStaleValue(c *gc.C) {
+	clk := testclock.NewClock(time.Now())
+	p := cache.NewWithConfig(cache.Config{
+		MaxAge:      time.Hour,
+		RefreshAge:  10 * time.Minute,
+		ErrorMaxAge: time.Minute,
+		Clock:       clk,
+	})
+	_, err := p.Get("a", fetchValue("a1"))
+	c.Assert(err, gc.IsNil)
+
+	clk.Advance(10*time.Minute + 1)
+	v, err := p.Get("a", fetchError(errgo.New("refresh failed")))
+	c.Assert(err, gc.IsNil)
+	c.Assert(v, gc.Equals, "a1")
+	waitNotInFlight(c, p, "a")
+
+	// The failed refresh did not replace the stale value,
+	// so another refresh is started.
+	v, err = p.Get("a", fetchValue("a2"))
+	c.Assert(err, gc.IsNil)
+	c.Assert(v, gc.Equals, "a1")
+	waitNotInFlight(c, p, "a")
+	v, err = p.Get("a", fetchError(errUnexpectedFetch))
+	c.Assert(err, gc.IsNil)
+	c.Assert(v, gc.Equals, "a2")
+}
+
+func (*suite) TestRefreshAgeExpiredEntryBlocks(c *gc.C) {
+	clk := testclock.NewClock(time.Now())
+	p := cache.NewWithConfig(cache.Config{
+		MaxAge:     time.Hour,
+		RefreshAge: 10 * time.Minute,
+		Clock:      clk,
+	})
+	_, err := p.Get("a", fetchValue("a1"))
+	c.Assert(err, gc.IsNil)
+
+	clk.Advance(time.Hour + 1)
+	v, err := p.Get("a", fetchValue("a2"))
+	c.Assert(err, gc.IsNil)
+	c.Assert(v, gc.Equals, "a2")
+}
+
+func waitNotInFlight(c *gc.C, p *cache.Cache, key cache.Key) {
+	for a := longAttempt.Start(); cache.InFlight(p, key); {
+		if !a.Next() {
+			c.Fatalf("timed out waiting for fetch to complete")
+		}
+	}
+}
+
+// getConcurrently calls p.Get for the given key from n goroutines
+// and checks that fetch is called only once and that every
+// goroutine receives the same result, which it returns.
+func getConcurrently(c *gc.C, p *cache.Cache, key cache.Key, n int, fetch func() (interface{}, error)) (interface{}, error) {
+	type result struct {
+		val interface{}
+		err error
+	}
+	misses := p.Stats().Misses
+	started := make(chan struct{})
+	release := make(chan struct{})
+	results := make(chan result)
+	go func() {
+		val, err := p.Get(key, func() (interface{}, error) {
+			close(started)
+			<-release
+			return fetch()
+		})
+		results <- result{val, err}
+	}()
+	<-started
+	for i := 1; i < n; i++ {
+		go func() {
+			val, err := p.Get(key, fetchError(errUnexpectedFetch))
+			results <- result{val, err}
+		}()
+	}
+	// Wait for all the other Gets to have missed, which
+	// they do while the first fetch is in progress, before
+	// releasing it.
+	for a := longAttempt.Start(); p.Stats().Misses-misses < int64(n); {
+		if !a.Next() {
+			c.Fatalf("timed out waiting for concurrent Gets")
+		}
+	}
+	close(release)
+	first := <-results
+	for i := 1; i < n; i++ {
+		r := <-results
+		c.Assert(r.val, gc.Equals, first.val)
+		c.Assert(r.err, gc.Equals, first.err)
+	}
+	return first.val, first.err
+}
+
+var longAttempt = utils.AttemptStrategy{
+	Total: 10 * time.Second,
+	Delay: time.Millisecond,
+}
+
 var errUnexpectedFetch = errgo.New("fetch called unexpectedly")
 
 func fetchError(err error) func() (interface{}, error) {
@@ -8,3 +8,12 @@ var GetAtTime = (*Cache).getAtTime
 func OldLen(c *Cache) int {
 	return len(c.old)
 }
+
+// InFlight reports whether a fetch of the given key
+// is in progress.
+func InFlight(c *Cache, key Key) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.inFlight[key]
+	return ok
+}
@@ -0,0 +1,117 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package cache
+
+import (
+	"time"
+)
+
+// Stats holds statistics about the use of a Cache.
+type Stats struct {
+	// Hits holds the number of Gets that were satisfied
+	// from the cache, including stale and cached error entries.
+	Hits int64
+
+	// Misses holds the number of Gets that had to wait for a
+	// fetch, whether they called fetch themselves or shared
+	// a fetch in progress.
+	Misses int64
+
+	// Fetches holds the number of completed fetches,
+	// including background refreshes.
+	Fetches int64
+
+	// FetchErrors holds the number of fetches that
+	// returned an error.
+	FetchErrors int64
+
+	// Evictions holds the number of entries evicted to keep
+	// the cache within Config.MaxEntries or Config.MaxCost.
+	Evictions int64
+
+	// Generations holds the number of times the cache has
+	// been refreshed by discarding entries that have not
+	// been used within the last MaxAge.
+	Generations int64
+
+	// Len holds the number of entries in the cache.
+	Len int
+
+	// AverageFetchLatency holds the mean time
+	// taken by completed fetches.
+	AverageFetchLatency time.Duration
+}
+
+// Observer is notified of events in a Cache. It can be
+// used to export cache metrics to a monitoring system.
+// Its methods are called without the cache's lock held,
+// possibly concurrently from several goroutines.
+type Observer interface {
+	// Hit is called when a Get for the given
+	// key is satisfied from the cache.
+	Hit(key Key)
+
+	// Miss is called when a Get for the given
+	// key must wait for a fetch.
+	Miss(key Key)
+
+	// Fetched is called when a fetch of the given key
+	// completes, with the time it took and any error
+	// it returned.
+	Fetched(key Key, latency time.Duration, err error)
+
+	// Evicted is called when the entry with the given
+	// key is evicted to keep the cache within its limits.
+	Evicted(key Key)
+
+	// Refreshed is called when the cache discards
+	// entries that have not been recently used.
+	Refreshed()
+}
+
+// Stats returns a snapshot of the cache's statistics.
+func (c *Cache) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.stats
+	stats.Len = len(c.old) + len(c.new)
+	if stats.Fetches > 0 {
+		stats.AverageFetchLatency = c.fetchTime / time.Duration(stats.Fetches)
+	}
+	return stats
+}
+
+// recordFetch updates the statistics for a completed fetch
+// and notifies any observer.
+// It must be called without c.mu held.
+func (c *Cache) recordFetch(key Key, latency time.Duration, err error) {
+	c.mu.Lock()
+	c.stats.Fetches++
+	c.fetchTime += latency
+	if err != nil {
+		c.stats.FetchErrors++
+	}
+	c.mu.Unlock()
+	if c.config.Observer != nil {
+		c.config.Observer.Fetched(key, latency, err)
+	}
+}
+
+// notifyGet notifies any observer of the result of a Get and
+// of any refresh that happened during it.
+// It must be called without c.mu held.
+func (c *Cache) notifyGet(key Key, hit, refreshed bool) {
+	o := c.config.Observer
+	if o == nil {
+		return
+	}
+	if refreshed {
+		o.Refreshed()
+	}
+	if hit {
+		o.Hit(key)
+	} else {
+		o.Miss(key)
+	}
+}
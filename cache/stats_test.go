@@ -0,0 +1,120 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package cache_test
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	gc "gopkg.in/check.v1"
+	"gopkg.in/errgo.v1"
+
+	"github.com/juju/utils/cache"
+	"github.com/juju/utils/clock/testclock"
+)
+
+type statsSuite struct{}
+
+var _ = gc.Suite(&statsSuite{})
+
+func (*statsSuite) TestStats(c *gc.C) {
+	clk := testclock.NewClock(time.Now())
+	p := cache.NewWithConfig(cache.Config{
+		MaxAge:     time.Hour,
+		MaxEntries: 2,
+		Clock:      clk,
+	})
+	c.Assert(p.Stats(), gc.Equals, cache.Stats{})
+
+	fetchSlowly := func(d time.Duration, val interface{}, err error) func() (interface{}, error) {
+		return func() (interface{}, error) {
+			clk.Advance(d)
+			return val, err
+		}
+	}
+	_, err := p.Get("a", fetchSlowly(time.Second, "a", nil))
+	c.Assert(err, gc.IsNil)
+	_, err = p.Get("a", fetchError(errUnexpectedFetch))
+	c.Assert(err, gc.IsNil)
+	_, err = p.Get("b", fetchSlowly(3*time.Second, nil, errgo.New("b")))
+	c.Assert(err, gc.ErrorMatches, "b")
+	_, err = p.Get("b", fetchSlowly(2*time.Second, "b", nil))
+	c.Assert(err, gc.IsNil)
+	_, err = p.Get("c", fetchSlowly(2*time.Second, "c", nil))
+	c.Assert(err, gc.IsNil)
+
+	c.Assert(p.Stats(), gc.Equals, cache.Stats{
+		Hits:                1,
+		Misses:              4,
+		Fetches:             4,
+		FetchErrors:         1,
+		Evictions:           1,
+		Generations:         1,
+		Len:                 2,
+		AverageFetchLatency: 2 * time.Second,
+	})
+}
+
+func (*statsSuite) TestObserver(c *gc.C) {
+	now := time.Now()
+	var obs recordingObserver
+	p := cache.NewWithConfig(cache.Config{
+		MaxAge:     time.Minute,
+		MaxEntries: 1,
+		Observer:   &obs,
+	})
+	_, err := cache.GetAtTime(p, "a", fetchValue("a"), now)
+	c.Assert(err, gc.IsNil)
+	_, err = cache.GetAtTime(p, "a", fetchError(errUnexpectedFetch), now)
+	c.Assert(err, gc.IsNil)
+	_, err = cache.GetAtTime(p, "b", fetchError(errgo.New("b")), now)
+	c.Assert(err, gc.ErrorMatches, "b")
+	_, err = cache.GetAtTime(p, "b", fetchValue("b"), now.Add(time.Minute+1))
+	c.Assert(err, gc.IsNil)
+
+	c.Assert(obs.events, gc.DeepEquals, []string{
+		"refreshed",
+		"miss a",
+		"fetched a <nil>",
+		"hit a",
+		"miss b",
+		"fetched b b",
+		"refreshed",
+		"miss b",
+		"fetched b <nil>",
+		"evicted a",
+	})
+}
+
+type recordingObserver struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (o *recordingObserver) record(f string, a ...interface{}) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.events = append(o.events, fmt.Sprintf(f, a...))
+}
+
+func (o *recordingObserver) Hit(key cache.Key) {
+	o.record("hit %v", key)
+}
+
+func (o *recordingObserver) Miss(key cache.Key) {
+	o.record("miss %v", key)
+}
+
+func (o *recordingObserver) Fetched(key cache.Key, latency time.Duration, err error) {
+	o.record("fetched %v %v", key, err)
+}
+
+func (o *recordingObserver) Evicted(key cache.Key) {
+	o.record("evicted %v", key)
+}
+
+func (o *recordingObserver) Refreshed() {
+	o.record("refreshed")
+}
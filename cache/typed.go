@@ -0,0 +1,65 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package cache
+
+import (
+	"time"
+)
+
+// Typed holds a time-limited set of values of type V for keys of
+// type K. It behaves exactly like Cache, but avoids the need for type
+// assertions on the values retrieved from it.
+type Typed[K comparable, V any] struct {
+	cache *Cache
+}
+
+// NewTyped returns a new Typed cache that will cache
+// items for at most maxAge.
+func NewTyped[K comparable, V any](maxAge time.Duration) *Typed[K, V] {
+	return NewTypedWithConfig[K, V](Config{
+		MaxAge: maxAge,
+	})
+}
+
+// NewTypedWithConfig returns a new Typed cache configured according
+// to the given configuration. Note that the Config.Cost and
+// Config.OnEvict functions are called with keys of type K and
+// values of type V.
+func NewTypedWithConfig[K comparable, V any](config Config) *Typed[K, V] {
+	return &Typed[K, V]{
+		cache: NewWithConfig(config),
+	}
+}
+
+// Len returns the total number of cached entries.
+func (c *Typed[K, V]) Len() int {
+	return c.cache.Len()
+}
+
+// Evict removes the entry with the given key from the cache if present.
+func (c *Typed[K, V]) Evict(key K) {
+	c.cache.Evict(key)
+}
+
+// EvictAll removes all entries from the cache.
+func (c *Typed[K, V]) EvictAll() {
+	c.cache.EvictAll()
+}
+
+// Stats returns a snapshot of the cache's statistics.
+func (c *Typed[K, V]) Stats() Stats {
+	return c.cache.Stats()
+}
+
+// Get returns the value for the given key, using fetch to fetch
+// the value if it is not found in the cache, as for Cache.Get.
+// If an error is returned, the returned value is the zero value
+// of V.
+func (c *Typed[K, V]) Get(key K, fetch func() (V, error)) (V, error) {
+	val, err := c.cache.Get(key, func() (interface{}, error) {
+		return fetch()
+	})
+	v, _ := val.(V)
+	return v, err
+}
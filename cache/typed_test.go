@@ -0,0 +1,77 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package cache_test
+
+import (
+	"time"
+
+	gc "gopkg.in/check.v1"
+	"gopkg.in/errgo.v1"
+
+	"github.com/juju/utils/cache"
+)
+
+type typedSuite struct{}
+
+var _ = gc.Suite(&typedSuite{})
+
+func (*typedSuite) TestGet(c *gc.C) {
+	p := cache.NewTyped[string, int](time.Hour)
+	v, err := p.Get("a", func() (int, error) {
+		return 2, nil
+	})
+	c.Assert(err, gc.IsNil)
+	c.Assert(v, gc.Equals, 2)
+
+	v, err = p.Get("a", func() (int, error) {
+		return 0, errUnexpectedFetch
+	})
+	c.Assert(err, gc.IsNil)
+	c.Assert(v, gc.Equals, 2)
+	c.Assert(p.Len(), gc.Equals, 1)
+
+	p.Evict("a")
+	c.Assert(p.Len(), gc.Equals, 0)
+}
+
+func (*typedSuite) TestGetError(c *gc.C) {
+	p := cache.NewTyped[string, *int](time.Hour)
+	expectErr := errgo.New("hello")
+	v, err := p.Get("a", func() (*int, error) {
+		return nil, expectErr
+	})
+	c.Assert(err, gc.ErrorMatches, "hello")
+	c.Assert(errgo.Cause(err), gc.Equals, expectErr)
+	c.Assert(v, gc.IsNil)
+}
+
+func (*typedSuite) TestNilInterfaceValue(c *gc.C) {
+	p := cache.NewTyped[int, error](time.Hour)
+	v, err := p.Get(1, func() (error, error) {
+		return nil, nil
+	})
+	c.Assert(err, gc.IsNil)
+	c.Assert(v, gc.IsNil)
+}
+
+func (*typedSuite) TestWithConfig(c *gc.C) {
+	var evicted []string
+	p := cache.NewTypedWithConfig[string, string](cache.Config{
+		MaxAge:     time.Hour,
+		MaxEntries: 1,
+		OnEvict: func(key cache.Key, value interface{}) {
+			evicted = append(evicted, key.(string)+"="+value.(string))
+		},
+	})
+	for _, key := range []string{"a", "b"} {
+		_, err := p.Get(key, func() (string, error) {
+			return key + key, nil
+		})
+		c.Assert(err, gc.IsNil)
+	}
+	c.Assert(evicted, gc.DeepEquals, []string{"a=aa"})
+	c.Assert(p.Stats().Evictions, gc.Equals, int64(1))
+	p.EvictAll()
+	c.Assert(p.Len(), gc.Equals, 0)
+}
@@ -18,6 +18,16 @@ type Clock interface {
 	// AfterFunc waits for the duration to elapse and then calls f in its own goroutine.
 	// It returns a Timer that can be used to cancel the call using its Stop method.
 	AfterFunc(time.Duration, func()) Timer
+
+	// NewTimer creates a new Timer that will send the current time
+	// on its channel after at least the duration has elapsed.
+	NewTimer(time.Duration) Timer
+
+	// NewTicker returns a new Ticker containing a channel that will
+	// send the current time on it after each tick. The period of the
+	// ticks is specified by the duration argument, which must be
+	// greater than zero.
+	NewTicker(time.Duration) Ticker
 }
 
 // Alarm returns a channel that will have the time sent on it at some point
@@ -28,11 +38,30 @@ func Alarm(c Clock, t time.Time) <-chan time.Time {
 	return c.After(t.Sub(c.Now()))
 }
 
+// Since returns the time elapsed since t according to c.
+//
+// This is short for c.Now().Sub(t).
+func Since(c Clock, t time.Time) time.Duration {
+	return c.Now().Sub(t)
+}
+
+// Until returns the duration until t according to c.
+//
+// This is short for t.Sub(c.Now()).
+func Until(c Clock, t time.Time) time.Duration {
+	return t.Sub(c.Now())
+}
+
 // The Timer type represents a single event.
-// A Timer must be created with AfterFunc.
+// A Timer must be created with AfterFunc or NewTimer.
 // This interface follows time.Timer's methods but provides easier mocking.
 type Timer interface {
 
+	// Chan returns the channel on which the time is delivered
+	// when the timer fires. It returns nil for timers created
+	// with AfterFunc, as time.Timer does.
+	Chan() <-chan time.Time
+
 	// Reset changes the timer to expire after duration d.
 	// It returns true if the timer had been active, false if
 	// the timer had expired or been stopped.
@@ -44,3 +73,23 @@ type Timer interface {
 	// from the channel succeeding incorrectly.
 	Stop() bool
 }
+
+// The Ticker type holds a channel that delivers ticks
+// of a clock at intervals.
+// A Ticker must be created with NewTicker.
+// This interface follows time.Ticker's methods but provides easier mocking.
+type Ticker interface {
+
+	// Chan returns the channel on which the ticks are delivered.
+	Chan() <-chan time.Time
+
+	// Reset stops the ticker and resets its period to the
+	// specified duration. The next tick will arrive after
+	// the new period elapses.
+	Reset(time.Duration)
+
+	// Stop turns off the ticker. After Stop, no more ticks
+	// will be sent. Stop does not close the channel, to prevent
+	// a read from the channel succeeding incorrectly.
+	Stop()
+}
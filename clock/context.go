@@ -0,0 +1,127 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package clock
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// WithDeadline returns a copy of the parent context with the deadline
+// adjusted to be no later than d. It behaves like context.WithDeadline
+// except that the deadline is measured by c, so the context's Done
+// channel is closed when c's AfterFunc fires rather than when
+// wall-clock time reaches d. If the parent's deadline is sooner than
+// d, it is used instead and is also measured by c, so the context is
+// done when c reaches it even if the parent measures it by another
+// clock.
+//
+// Canceling the context releases resources associated with it, so
+// code should call cancel as soon as the operations running in the
+// context complete.
+func WithDeadline(parent context.Context, c Clock, d time.Time) (context.Context, context.CancelFunc) {
+	if cur, ok := parent.Deadline(); ok && cur.Before(d) {
+		// The parent's deadline is already sooner than the new
+		// one, but the parent may not be measuring it by c.
+		d = cur
+	}
+	ctx := &deadlineContext{
+		Context:  parent,
+		deadline: d,
+		done:     make(chan struct{}),
+	}
+	cancel := func() { ctx.cancel(context.Canceled) }
+	dur := Until(c, d)
+	if dur <= 0 {
+		ctx.cancel(context.DeadlineExceeded)
+		return ctx, cancel
+	}
+	ctx.mu.Lock()
+	ctx.timer = c.AfterFunc(dur, func() {
+		ctx.cancel(context.DeadlineExceeded)
+	})
+	ctx.mu.Unlock()
+	if parent.Done() != nil {
+		go func() {
+			select {
+			case <-parent.Done():
+				ctx.cancel(parent.Err())
+			case <-ctx.done:
+			}
+		}()
+	}
+	return ctx, cancel
+}
+
+// WithTimeout returns WithDeadline(parent, c, c.Now().Add(timeout)).
+func WithTimeout(parent context.Context, c Clock, timeout time.Duration) (context.Context, context.CancelFunc) {
+	return WithDeadline(parent, c, c.Now().Add(timeout))
+}
+
+// Sleep pauses the current goroutine for at least the duration d
+// as measured by c. It returns early with ctx.Err() if the context
+// is done before the duration elapses.
+func Sleep(ctx context.Context, c Clock, d time.Duration) error {
+	t := c.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.Chan():
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// deadlineContext implements context.Context with a deadline
+// governed by a Clock. Values are looked up in the parent context.
+type deadlineContext struct {
+	context.Context
+	deadline time.Time
+	done     chan struct{}
+
+	mu    sync.Mutex
+	timer Timer
+	err   error
+}
+
+// Deadline implements context.Context.Deadline.
+func (ctx *deadlineContext) Deadline() (time.Time, bool) {
+	return ctx.deadline, true
+}
+
+// Done implements context.Context.Done.
+func (ctx *deadlineContext) Done() <-chan struct{} {
+	return ctx.done
+}
+
+// Err implements context.Context.Err.
+func (ctx *deadlineContext) Err() error {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	return ctx.err
+}
+
+// String returns a description of the context
+// suitable for debugging.
+func (ctx *deadlineContext) String() string {
+	return fmt.Sprintf("%v.WithDeadline(%s)", ctx.Context, ctx.deadline)
+}
+
+// cancel closes the context's Done channel and stops its
+// timer, recording err as the reason. Only the first call
+// has any effect.
+func (ctx *deadlineContext) cancel(err error) {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	if ctx.err != nil {
+		return
+	}
+	ctx.err = err
+	close(ctx.done)
+	if ctx.timer != nil {
+		ctx.timer.Stop()
+	}
+}
@@ -0,0 +1,184 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package clock_test
+
+import (
+	"context"
+	"time"
+
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/utils/clock"
+	"github.com/juju/utils/clock/testclock"
+)
+
+const longWait = 10 * time.Second
+
+type contextSuite struct {
+	testing.IsolationSuite
+}
+
+var _ = gc.Suite(&contextSuite{})
+
+var t0 = time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func (*contextSuite) TestWithDeadline(c *gc.C) {
+	cl := testclock.NewClock(t0)
+	ctx, cancel := clock.WithDeadline(context.Background(), cl, t0.Add(time.Minute))
+	defer cancel()
+
+	deadline, ok := ctx.Deadline()
+	c.Assert(ok, jc.IsTrue)
+	c.Assert(deadline, gc.Equals, t0.Add(time.Minute))
+	c.Assert(ctx.Err(), jc.ErrorIsNil)
+
+	err := cl.WaitAdvance(59*time.Second, longWait, 1)
+	c.Assert(err, jc.ErrorIsNil)
+	assertNotDone(c, ctx)
+
+	cl.Advance(time.Second)
+	assertDone(c, ctx)
+	c.Assert(ctx.Err(), gc.Equals, context.DeadlineExceeded)
+
+	// Canceling after expiry does not change the error.
+	cancel()
+	c.Assert(ctx.Err(), gc.Equals, context.DeadlineExceeded)
+}
+
+func (*contextSuite) TestWithDeadlineCancel(c *gc.C) {
+	cl := testclock.NewClock(t0)
+	ctx, cancel := clock.WithDeadline(context.Background(), cl, t0.Add(time.Minute))
+	cancel()
+	assertDone(c, ctx)
+	c.Assert(ctx.Err(), gc.Equals, context.Canceled)
+
+	cl.Advance(time.Hour)
+	c.Assert(ctx.Err(), gc.Equals, context.Canceled)
+}
+
+func (*contextSuite) TestWithDeadlineParentCanceled(c *gc.C) {
+	cl := testclock.NewClock(t0)
+	parent, cancelParent := context.WithCancel(context.Background())
+	ctx, cancel := clock.WithDeadline(parent, cl, t0.Add(time.Minute))
+	defer cancel()
+
+	cancelParent()
+	assertDone(c, ctx)
+	c.Assert(ctx.Err(), gc.Equals, context.Canceled)
+}
+
+func (*contextSuite) TestWithDeadlineInPast(c *gc.C) {
+	cl := testclock.NewClock(t0)
+	ctx, cancel := clock.WithDeadline(context.Background(), cl, t0.Add(-time.Second))
+	defer cancel()
+	assertDone(c, ctx)
+	c.Assert(ctx.Err(), gc.Equals, context.DeadlineExceeded)
+}
+
+func (*contextSuite) TestWithDeadlineParentSooner(c *gc.C) {
+	cl := testclock.NewClock(t0)
+	parent, cancelParent := clock.WithDeadline(context.Background(), cl, t0.Add(time.Second))
+	defer cancelParent()
+	ctx, cancel := clock.WithDeadline(parent, cl, t0.Add(time.Minute))
+	defer cancel()
+
+	deadline, ok := ctx.Deadline()
+	c.Assert(ok, jc.IsTrue)
+	c.Assert(deadline, gc.Equals, t0.Add(time.Second))
+
+	cl.Advance(time.Second)
+	assertDone(c, ctx)
+	c.Assert(ctx.Err(), gc.Equals, context.DeadlineExceeded)
+}
+
+func (*contextSuite) TestWithDeadlineWallClockParentSooner(c *gc.C) {
+	// The parent's deadline is measured by the wall clock,
+	// so it will not pass during the test.
+	now := time.Now()
+	cl := testclock.NewClock(now)
+	parent, cancelParent := context.WithDeadline(context.Background(), now.Add(time.Hour))
+	defer cancelParent()
+	ctx, cancel := clock.WithDeadline(parent, cl, now.Add(2*time.Hour))
+	defer cancel()
+
+	deadline, ok := ctx.Deadline()
+	c.Assert(ok, jc.IsTrue)
+	c.Assert(deadline, gc.Equals, now.Add(time.Hour))
+
+	// The parent's deadline is measured by the test clock too.
+	err := cl.WaitAdvance(time.Hour, longWait, 1)
+	c.Assert(err, jc.ErrorIsNil)
+	assertDone(c, ctx)
+	c.Assert(ctx.Err(), gc.Equals, context.DeadlineExceeded)
+	assertNotDone(c, parent)
+}
+
+func (*contextSuite) TestWithTimeout(c *gc.C) {
+	cl := testclock.NewClock(t0)
+	ctx, cancel := clock.WithTimeout(context.Background(), cl, time.Minute)
+	defer cancel()
+
+	deadline, _ := ctx.Deadline()
+	c.Assert(deadline, gc.Equals, t0.Add(time.Minute))
+	cl.Advance(time.Minute)
+	assertDone(c, ctx)
+}
+
+func (*contextSuite) TestSleep(c *gc.C) {
+	cl := testclock.NewClock(t0)
+	done := make(chan error)
+	go func() {
+		done <- clock.Sleep(context.Background(), cl, time.Second)
+	}()
+	err := cl.WaitAdvance(time.Second, longWait, 1)
+	c.Assert(err, jc.ErrorIsNil)
+	select {
+	case err := <-done:
+		c.Assert(err, jc.ErrorIsNil)
+	case <-time.After(longWait):
+		c.Fatalf("Sleep did not return")
+	}
+}
+
+func (*contextSuite) TestSleepCanceled(c *gc.C) {
+	cl := testclock.NewClock(t0)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- clock.Sleep(ctx, cl, time.Second)
+	}()
+	<-cl.Notify()
+	cancel()
+	select {
+	case err := <-done:
+		c.Assert(err, gc.Equals, context.Canceled)
+	case <-time.After(longWait):
+		c.Fatalf("Sleep did not return")
+	}
+}
+
+func (*contextSuite) TestSinceUntil(c *gc.C) {
+	cl := testclock.NewClock(t0)
+	cl.Advance(time.Minute)
+	c.Assert(clock.Since(cl, t0), gc.Equals, time.Minute)
+	c.Assert(clock.Until(cl, t0.Add(time.Hour)), gc.Equals, 59*time.Minute)
+}
+
+func assertDone(c *gc.C, ctx context.Context) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(longWait):
+		c.Fatalf("context not done")
+	}
+}
+
+func assertNotDone(c *gc.C, ctx context.Context) {
+	select {
+	case <-ctx.Done():
+		c.Fatalf("context done unexpectedly: %v", ctx.Err())
+	default:
+	}
+}
@@ -0,0 +1,275 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+// Package testclock provides a deterministic implementation of
+// clock.Clock for use in tests.
+package testclock
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/juju/errors"
+
+	"github.com/juju/utils/clock"
+)
+
+// notifyBufferSize holds the number of notifications that
+// may be pending on a Clock's Notify channel before
+// the clock panics.
+const notifyBufferSize = 10000
+
+// Clock implements a mock clock.Clock for testing purposes.
+// Time only moves forward when Advance or WaitAdvance
+// is called.
+type Clock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiting []*timer // timers waiting to fire, sorted by deadline.
+	notify  chan struct{}
+}
+
+// NewClock returns a new clock set to the supplied time.
+func NewClock(now time.Time) *Clock {
+	return &Clock{
+		now:    now,
+		notify: make(chan struct{}, notifyBufferSize),
+	}
+}
+
+// Now is part of the clock.Clock interface.
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// After is part of the clock.Clock interface.
+func (c *Clock) After(d time.Duration) <-chan time.Time {
+	return c.NewTimer(d).Chan()
+}
+
+// NewTimer is part of the clock.Clock interface.
+func (c *Clock) NewTimer(d time.Duration) clock.Timer {
+	t := &timer{
+		clock: c,
+		c:     make(chan time.Time, 1),
+	}
+	c.addTimer(t, d)
+	return t
+}
+
+// NewTicker is part of the clock.Clock interface.
+func (c *Clock) NewTicker(d time.Duration) clock.Ticker {
+	if d <= 0 {
+		panic("non-positive interval for NewTicker")
+	}
+	t := &ticker{timer{
+		clock:  c,
+		c:      make(chan time.Time, 1),
+		period: d,
+	}}
+	c.addTimer(&t.timer, d)
+	return t
+}
+
+// AfterFunc is part of the clock.Clock interface.
+func (c *Clock) AfterFunc(d time.Duration, f func()) clock.Timer {
+	t := &timer{
+		clock: c,
+		f:     f,
+	}
+	c.addTimer(t, d)
+	return t
+}
+
+// Advance advances the clock by the supplied duration,
+// firing any timers whose deadlines have passed.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	for len(c.waiting) > 0 && !c.waiting[0].deadline.After(c.now) {
+		t := c.waiting[0]
+		c.waiting = c.waiting[1:]
+		t.fire(c.now)
+		if t.period > 0 {
+			// Tickers are rescheduled relative to their
+			// previous deadline so that they do not drift.
+			t.deadline = t.deadline.Add(t.period)
+			c.insert(t)
+		}
+	}
+}
+
+// WaitAdvance waits for at least n timers to be waiting on
+// the clock, then advances it by d. It returns an error
+// if the timers have not been added after waiting for w.
+func (c *Clock) WaitAdvance(d, w time.Duration, n int) error {
+	pause := w / 10
+	if pause > 10*time.Millisecond {
+		pause = 10 * time.Millisecond
+	}
+	timeout := time.After(w)
+	next := time.After(0)
+	for {
+		select {
+		case <-timeout:
+			if got := c.numWaiting(); got < n {
+				return errors.Errorf("got %d timers added after waiting %s: wanted %d", got, w, n)
+			}
+			c.Advance(d)
+			return nil
+		case <-next:
+			if c.numWaiting() >= n {
+				c.Advance(d)
+				return nil
+			}
+			next = time.After(pause)
+		}
+	}
+}
+
+// Notify returns a channel that receives a value
+// every time a timer is added to the clock, whether
+// by After, AfterFunc, NewTimer, NewTicker or by
+// resetting an existing timer or ticker.
+// Tests can use this to wait until code under test is
+// blocked on the clock before advancing it.
+func (c *Clock) Notify() <-chan struct{} {
+	return c.notify
+}
+
+// numWaiting returns the number of timers waiting to fire.
+func (c *Clock) numWaiting() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.waiting)
+}
+
+// addTimer schedules t to fire after d and sends
+// a notification that it has been added.
+func (c *Clock) addTimer(t *timer, d time.Duration) {
+	c.mu.Lock()
+	c.schedule(t, d)
+	c.mu.Unlock()
+	c.notifyAdded()
+}
+
+// notifyAdded sends a notification that a timer
+// has been added to the clock.
+func (c *Clock) notifyAdded() {
+	select {
+	case c.notify <- struct{}{}:
+	default:
+		panic("testclock: notification buffer full")
+	}
+}
+
+// schedule inserts t into the waiting timers so that
+// it fires after d. It must be called with c.mu held.
+// If d is not positive, the timer fires immediately.
+func (c *Clock) schedule(t *timer, d time.Duration) {
+	t.deadline = c.now.Add(d)
+	if d <= 0 {
+		t.fire(c.now)
+		return
+	}
+	c.insert(t)
+}
+
+// insert adds t to the waiting timers, keeping them
+// sorted by deadline. It must be called with c.mu held.
+func (c *Clock) insert(t *timer) {
+	i := sort.Search(len(c.waiting), func(i int) bool {
+		return c.waiting[i].deadline.After(t.deadline)
+	})
+	c.waiting = append(c.waiting, nil)
+	copy(c.waiting[i+1:], c.waiting[i:])
+	c.waiting[i] = t
+}
+
+// unschedule removes t from the waiting timers, reporting
+// whether it was found. It must be called with c.mu held.
+func (c *Clock) unschedule(t *timer) bool {
+	for i, wt := range c.waiting {
+		if wt == t {
+			c.waiting = append(c.waiting[:i], c.waiting[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// timer implements clock.Timer for a Clock.
+type timer struct {
+	clock    *Clock
+	deadline time.Time
+
+	// Exactly one of c and f is set.
+	c chan time.Time
+	f func()
+
+	// period holds the interval between ticks
+	// when the timer is used by a ticker.
+	period time.Duration
+}
+
+// fire delivers the timer's event. It must be called
+// with the clock's mutex held.
+func (t *timer) fire(now time.Time) {
+	if t.f != nil {
+		go t.f()
+		return
+	}
+	select {
+	case t.c <- now:
+	default:
+	}
+}
+
+// Chan is part of the clock.Timer interface.
+func (t *timer) Chan() <-chan time.Time {
+	return t.c
+}
+
+// Reset is part of the clock.Timer interface.
+func (t *timer) Reset(d time.Duration) bool {
+	c := t.clock
+	c.mu.Lock()
+	active := c.unschedule(t)
+	c.schedule(t, d)
+	c.mu.Unlock()
+	c.notifyAdded()
+	return active
+}
+
+// Stop is part of the clock.Timer interface.
+func (t *timer) Stop() bool {
+	c := t.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.unschedule(t)
+}
+
+// ticker implements clock.Ticker for a Clock.
+type ticker struct {
+	timer
+}
+
+// Reset is part of the clock.Ticker interface.
+func (t *ticker) Reset(d time.Duration) {
+	if d <= 0 {
+		panic("non-positive interval for Ticker.Reset")
+	}
+	t.clock.mu.Lock()
+	t.period = d
+	t.clock.mu.Unlock()
+	t.timer.Reset(d)
+}
+
+// Stop is part of the clock.Ticker interface.
+func (t *ticker) Stop() {
+	t.timer.Stop()
+}
@@ -0,0 +1,235 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package testclock_test
+
+import (
+	"time"
+
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/utils/clock"
+	"github.com/juju/utils/clock/testclock"
+)
+
+const longWait = 10 * time.Second
+
+type clockSuite struct {
+	testing.IsolationSuite
+}
+
+var _ = gc.Suite(&clockSuite{})
+
+var _ clock.Clock = (*testclock.Clock)(nil)
+
+var t0 = time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func (*clockSuite) TestNow(c *gc.C) {
+	cl := testclock.NewClock(t0)
+	c.Assert(cl.Now(), gc.Equals, t0)
+	cl.Advance(time.Minute)
+	c.Assert(cl.Now(), gc.Equals, t0.Add(time.Minute))
+}
+
+func (*clockSuite) TestAfter(c *gc.C) {
+	cl := testclock.NewClock(t0)
+	ch := cl.After(time.Second)
+	assertNotify(c, cl)
+
+	cl.Advance(999 * time.Millisecond)
+	assertNotReceived(c, ch)
+
+	cl.Advance(time.Millisecond)
+	select {
+	case t := <-ch:
+		c.Assert(t, gc.Equals, t0.Add(time.Second))
+	default:
+		c.Fatalf("timer did not fire")
+	}
+}
+
+func (*clockSuite) TestAfterZeroDuration(c *gc.C) {
+	cl := testclock.NewClock(t0)
+	select {
+	case t := <-cl.After(0):
+		c.Assert(t, gc.Equals, t0)
+	default:
+		c.Fatalf("timer did not fire")
+	}
+}
+
+func (*clockSuite) TestAfterFunc(c *gc.C) {
+	cl := testclock.NewClock(t0)
+	called := make(chan struct{})
+	cl.AfterFunc(time.Second, func() {
+		close(called)
+	})
+	cl.Advance(time.Second)
+	select {
+	case <-called:
+	case <-time.After(longWait):
+		c.Fatalf("func was not called")
+	}
+}
+
+func (*clockSuite) TestTimersFireInOrder(c *gc.C) {
+	cl := testclock.NewClock(t0)
+	ch3 := cl.After(3 * time.Second)
+	ch1 := cl.After(time.Second)
+	ch2 := cl.After(2 * time.Second)
+
+	cl.Advance(time.Second)
+	c.Assert(<-ch1, gc.Equals, t0.Add(time.Second))
+	assertNotReceived(c, ch2)
+	assertNotReceived(c, ch3)
+
+	cl.Advance(5 * time.Second)
+	c.Assert(<-ch2, gc.Equals, t0.Add(6*time.Second))
+	c.Assert(<-ch3, gc.Equals, t0.Add(6*time.Second))
+}
+
+func (*clockSuite) TestStop(c *gc.C) {
+	cl := testclock.NewClock(t0)
+	t := cl.AfterFunc(time.Second, func() {
+		c.Errorf("stopped timer fired")
+	})
+	c.Assert(t.Stop(), jc.IsTrue)
+	c.Assert(t.Stop(), jc.IsFalse)
+	cl.Advance(time.Hour)
+}
+
+func (*clockSuite) TestReset(c *gc.C) {
+	cl := testclock.NewClock(t0)
+	called := make(chan struct{}, 2)
+	t := cl.AfterFunc(time.Second, func() {
+		called <- struct{}{}
+	})
+	assertNotify(c, cl)
+
+	c.Assert(t.Reset(time.Minute), jc.IsTrue)
+	assertNotify(c, cl)
+	cl.Advance(time.Second)
+	select {
+	case <-called:
+		c.Fatalf("reset timer fired early")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	cl.Advance(time.Minute)
+	select {
+	case <-called:
+	case <-time.After(longWait):
+		c.Fatalf("func was not called")
+	}
+	c.Assert(t.Reset(time.Second), jc.IsFalse)
+}
+
+func (*clockSuite) TestNewTimer(c *gc.C) {
+	cl := testclock.NewClock(t0)
+	t := cl.NewTimer(time.Second)
+	assertNotify(c, cl)
+	assertNotReceived(c, t.Chan())
+
+	cl.Advance(time.Second)
+	c.Assert(<-t.Chan(), gc.Equals, t0.Add(time.Second))
+	c.Assert(t.Stop(), jc.IsFalse)
+
+	c.Assert(t.Reset(time.Second), jc.IsFalse)
+	c.Assert(t.Stop(), jc.IsTrue)
+	cl.Advance(time.Hour)
+	assertNotReceived(c, t.Chan())
+}
+
+func (*clockSuite) TestAfterFuncTimerHasNoChan(c *gc.C) {
+	cl := testclock.NewClock(t0)
+	t := cl.AfterFunc(time.Second, func() {})
+	c.Assert(t.Chan(), gc.IsNil)
+}
+
+func (*clockSuite) TestNewTicker(c *gc.C) {
+	cl := testclock.NewClock(t0)
+	t := cl.NewTicker(time.Second)
+	assertNotify(c, cl)
+	assertNotReceived(c, t.Chan())
+
+	for i := 1; i <= 3; i++ {
+		cl.Advance(time.Second)
+		c.Assert(<-t.Chan(), gc.Equals, t0.Add(time.Duration(i)*time.Second))
+	}
+
+	// Ticks that are not received are dropped.
+	cl.Advance(5 * time.Second)
+	c.Assert(<-t.Chan(), gc.Equals, t0.Add(8*time.Second))
+	assertNotReceived(c, t.Chan())
+
+	// The ticker continues to tick at its original phase.
+	cl.Advance(500 * time.Millisecond)
+	assertNotReceived(c, t.Chan())
+	cl.Advance(500 * time.Millisecond)
+	c.Assert(<-t.Chan(), gc.Equals, t0.Add(9*time.Second))
+}
+
+func (*clockSuite) TestTickerReset(c *gc.C) {
+	cl := testclock.NewClock(t0)
+	t := cl.NewTicker(time.Second)
+	t.Reset(time.Minute)
+	cl.Advance(time.Second)
+	assertNotReceived(c, t.Chan())
+	cl.Advance(time.Minute)
+	c.Assert(<-t.Chan(), gc.Equals, t0.Add(time.Minute+time.Second))
+}
+
+func (*clockSuite) TestTickerStop(c *gc.C) {
+	cl := testclock.NewClock(t0)
+	t := cl.NewTicker(time.Second)
+	t.Stop()
+	cl.Advance(time.Hour)
+	assertNotReceived(c, t.Chan())
+}
+
+func (*clockSuite) TestNewTickerPanicsWithNonPositiveInterval(c *gc.C) {
+	cl := testclock.NewClock(t0)
+	c.Assert(func() { cl.NewTicker(0) }, gc.PanicMatches, "non-positive interval for NewTicker")
+}
+
+func (*clockSuite) TestWaitAdvance(c *gc.C) {
+	cl := testclock.NewClock(t0)
+	done := make(chan time.Time)
+	go func() {
+		done <- <-cl.After(time.Second)
+	}()
+	err := cl.WaitAdvance(time.Second, longWait, 1)
+	c.Assert(err, jc.ErrorIsNil)
+	select {
+	case t := <-done:
+		c.Assert(t, gc.Equals, t0.Add(time.Second))
+	case <-time.After(longWait):
+		c.Fatalf("timer did not fire")
+	}
+}
+
+func (*clockSuite) TestWaitAdvanceTimeout(c *gc.C) {
+	cl := testclock.NewClock(t0)
+	cl.After(time.Second)
+	err := cl.WaitAdvance(time.Second, 10*time.Millisecond, 2)
+	c.Assert(err, gc.ErrorMatches, "got 1 timers added after waiting 10ms: wanted 2")
+	c.Assert(cl.Now(), gc.Equals, t0)
+}
+
+func assertNotify(c *gc.C, cl *testclock.Clock) {
+	select {
+	case <-cl.Notify():
+	default:
+		c.Fatalf("no notification received")
+	}
+}
+
+func assertNotReceived(c *gc.C, ch <-chan time.Time) {
+	select {
+	case t := <-ch:
+		c.Fatalf("unexpected time received: %v", t)
+	default:
+	}
+}
@@ -24,6 +24,37 @@ func (wallClock) After(d time.Duration) <-chan time.Time {
 	return time.After(d)
 }
 
+// AfterFunc is part of the Clock interface.
 func (wallClock) AfterFunc(d time.Duration, f func()) Timer {
-	return time.AfterFunc(d, f)
+	return wallTimer{time.AfterFunc(d, f)}
+}
+
+// NewTimer is part of the Clock interface.
+func (wallClock) NewTimer(d time.Duration) Timer {
+	return wallTimer{time.NewTimer(d)}
+}
+
+// NewTicker is part of the Clock interface.
+func (wallClock) NewTicker(d time.Duration) Ticker {
+	return wallTicker{time.NewTicker(d)}
+}
+
+// wallTimer implements the Timer interface.
+type wallTimer struct {
+	*time.Timer
+}
+
+// Chan is part of the Timer interface.
+func (t wallTimer) Chan() <-chan time.Time {
+	return t.C
+}
+
+// wallTicker implements the Ticker interface.
+type wallTicker struct {
+	*time.Ticker
+}
+
+// Chan is part of the Ticker interface.
+func (t wallTicker) Chan() <-chan time.Time {
+	return t.C
 }
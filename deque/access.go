@@ -0,0 +1,222 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package deque
+
+import (
+	"container/list"
+)
+
+// cursor identifies the position of an item
+// within the blocks of a deque.
+type cursor[T any] struct {
+	elem *list.Element
+	idx  int
+}
+
+func (c *cursor[T]) block() blockT[T] {
+	return c.elem.Value.(blockT[T])
+}
+
+func (c *cursor[T]) get() T {
+	return c.block()[c.idx]
+}
+
+func (c *cursor[T]) set(item T) {
+	c.block()[c.idx] = item
+}
+
+// next moves the cursor to the following item.
+func (c *cursor[T]) next() {
+	c.idx++
+	if c.idx == blockLen {
+		c.elem = c.elem.Next()
+		c.idx = 0
+	}
+}
+
+// prev moves the cursor to the preceding item.
+func (c *cursor[T]) prev() {
+	c.idx--
+	if c.idx == -1 {
+		c.elem = c.elem.Prev()
+		c.idx = blockLen - 1
+	}
+}
+
+// cursorAt returns a cursor positioned at the i'th item in the
+// deque, walking the blocks from whichever end is closer.
+// It panics if i is out of range.
+func (d *Of[T]) cursorAt(i int) cursor[T] {
+	if i < 0 || i >= d.len {
+		panic("deque: index out of range")
+	}
+	pos := d.frontIdx + i
+	n := pos / blockLen
+	if n <= d.blocks.Len()/2 {
+		elem := d.blocks.Front()
+		for ; n > 0; n-- {
+			elem = elem.Next()
+		}
+		return cursor[T]{elem, pos % blockLen}
+	}
+	elem := d.blocks.Back()
+	for n = d.blocks.Len() - 1 - n; n > 0; n-- {
+		elem = elem.Prev()
+	}
+	return cursor[T]{elem, pos % blockLen}
+}
+
+// At returns the i'th item in the queue, where the
+// front item is at index 0. It panics if i is out of range.
+func (d *Of[T]) At(i int) T {
+	c := d.cursorAt(i)
+	return c.get()
+}
+
+// Set replaces the i'th item in the queue, where the
+// front item is at index 0. It panics if i is out of range.
+func (d *Of[T]) Set(i int, item T) {
+	c := d.cursorAt(i)
+	c.set(item)
+}
+
+// PeekFront returns the item at the front of the queue without
+// removing it. The returned flag is true unless the queue is empty.
+func (d *Of[T]) PeekFront() (T, bool) {
+	if d.len < 1 {
+		var zero T
+		return zero, false
+	}
+	return d.blocks.Front().Value.(blockT[T])[d.frontIdx], true
+}
+
+// PeekBack returns the item at the back of the queue without
+// removing it. The returned flag is true unless the queue is empty.
+func (d *Of[T]) PeekBack() (T, bool) {
+	if d.len < 1 {
+		var zero T
+		return zero, false
+	}
+	return d.blocks.Back().Value.(blockT[T])[d.backIdx], true
+}
+
+// RemoveAt removes the i'th item from the queue, where the front item
+// is at index 0, and returns it. Items are moved from whichever end
+// of the queue is closer to fill the gap. It panics if i is out of
+// range.
+func (d *Of[T]) RemoveAt(i int) T {
+	c := d.cursorAt(i)
+	item := c.get()
+	if i < d.len/2 {
+		// Shift the items in front of i back by one.
+		for ; i > 0; i-- {
+			dst := c
+			c.prev()
+			dst.set(c.get())
+		}
+		d.PopFront()
+	} else {
+		// Shift the items behind i forward by one.
+		for ; i < d.len-1; i++ {
+			dst := c
+			c.next()
+			dst.set(c.get())
+		}
+		d.PopBack()
+	}
+	return item
+}
+
+// Rotate rotates the queue n steps to the back. If n is
+// negative, it rotates the queue to the front. Rotating one
+// step to the back is equivalent to d.PushFront(d.PopBack()).
+func (d *Of[T]) Rotate(n int) {
+	if d.len < 2 {
+		return
+	}
+	n %= d.len
+	// Take the shorter route.
+	if n > d.len/2 {
+		n -= d.len
+	} else if n < -d.len/2 {
+		n += d.len
+	}
+	for ; n > 0; n-- {
+		item, _ := d.PopBack()
+		d.PushFront(item)
+	}
+	for ; n < 0; n++ {
+		item, _ := d.PopFront()
+		d.PushBack(item)
+	}
+}
+
+// Clear removes all the items from the queue.
+func (d *Of[T]) Clear() {
+	d.blocks.Init()
+	d.blocks.PushBack(newBlock[T]())
+	d.recenter()
+	d.len = 0
+}
+
+// Iterator iterates over the items in a deque. The
+// deque must not be modified during the iteration.
+type Iterator[T any] struct {
+	cursor    cursor[T]
+	remaining int
+	reverse   bool
+	started   bool
+}
+
+// Iter returns an iterator over the items in the
+// queue, from the front to the back.
+func (d *Of[T]) Iter() *Iterator[T] {
+	return d.iter(false)
+}
+
+// ReverseIter returns an iterator over the items in
+// the queue, from the back to the front.
+func (d *Of[T]) ReverseIter() *Iterator[T] {
+	return d.iter(true)
+}
+
+func (d *Of[T]) iter(reverse bool) *Iterator[T] {
+	it := &Iterator[T]{
+		remaining: d.len,
+		reverse:   reverse,
+	}
+	if d.len > 0 {
+		if reverse {
+			it.cursor = cursor[T]{d.blocks.Back(), d.backIdx}
+		} else {
+			it.cursor = cursor[T]{d.blocks.Front(), d.frontIdx}
+		}
+	}
+	return it
+}
+
+// Next advances the iterator to the next item, which
+// will then be available through the Value method. It
+// returns false when there are no more items.
+func (it *Iterator[T]) Next() bool {
+	if it.remaining == 0 {
+		return false
+	}
+	if it.started {
+		if it.reverse {
+			it.cursor.prev()
+		} else {
+			it.cursor.next()
+		}
+	}
+	it.started = true
+	it.remaining--
+	return true
+}
+
+// Value returns the current item. It must only
+// be called after Next has returned true.
+func (it *Iterator[T]) Value() T {
+	return it.cursor.get()
+}
@@ -0,0 +1,151 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package deque_test
+
+import (
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/utils/deque"
+)
+
+type accessSuite struct {
+	testing.IsolationSuite
+}
+
+var _ = gc.Suite(&accessSuite{})
+
+// newTestDeque returns a deque holding the integers [0, n),
+// pushed so that they span several partially filled blocks.
+func newTestDeque(n int) *deque.Of[int] {
+	d := deque.NewOf[int]()
+	for i := n/2 - 1; i >= 0; i-- {
+		d.PushFront(i)
+	}
+	for i := n / 2; i < n; i++ {
+		d.PushBack(i)
+	}
+	return d
+}
+
+func contents(d *deque.Of[int]) []int {
+	result := []int{}
+	for it := d.Iter(); it.Next(); {
+		result = append(result, it.Value())
+	}
+	return result
+}
+
+func sequence(from, to int) []int {
+	result := []int{}
+	for i := from; i < to; i++ {
+		result = append(result, i)
+	}
+	return result
+}
+
+func (s *accessSuite) TestAtSet(c *gc.C) {
+	d := newTestDeque(testLen)
+	for i := 0; i < testLen; i++ {
+		c.Assert(d.At(i), gc.Equals, i)
+	}
+	for i := 0; i < testLen; i++ {
+		d.Set(i, -i)
+	}
+	for i := 0; i < testLen; i++ {
+		c.Assert(d.At(i), gc.Equals, -i)
+	}
+}
+
+func (s *accessSuite) TestAtOutOfRange(c *gc.C) {
+	d := newTestDeque(3)
+	c.Assert(func() { d.At(3) }, gc.PanicMatches, "deque: index out of range")
+	c.Assert(func() { d.At(-1) }, gc.PanicMatches, "deque: index out of range")
+	c.Assert(func() { d.Set(3, 0) }, gc.PanicMatches, "deque: index out of range")
+}
+
+func (s *accessSuite) TestPeek(c *gc.C) {
+	d := deque.NewOf[int]()
+	_, ok := d.PeekFront()
+	c.Assert(ok, jc.IsFalse)
+	_, ok = d.PeekBack()
+	c.Assert(ok, jc.IsFalse)
+
+	d = newTestDeque(testLen)
+	v, ok := d.PeekFront()
+	c.Assert(ok, jc.IsTrue)
+	c.Assert(v, gc.Equals, 0)
+	v, ok = d.PeekBack()
+	c.Assert(ok, jc.IsTrue)
+	c.Assert(v, gc.Equals, testLen-1)
+	c.Assert(d.Len(), gc.Equals, testLen)
+}
+
+func (s *accessSuite) TestIter(c *gc.C) {
+	c.Assert(contents(deque.NewOf[int]()), jc.DeepEquals, []int{})
+	c.Assert(contents(newTestDeque(testLen)), jc.DeepEquals, sequence(0, testLen))
+}
+
+func (s *accessSuite) TestReverseIter(c *gc.C) {
+	d := newTestDeque(testLen)
+	i := testLen
+	for it := d.ReverseIter(); it.Next(); {
+		i--
+		c.Assert(it.Value(), gc.Equals, i)
+	}
+	c.Assert(i, gc.Equals, 0)
+	c.Assert(deque.NewOf[int]().ReverseIter().Next(), jc.IsFalse)
+}
+
+func (s *accessSuite) TestRemoveAt(c *gc.C) {
+	const n = 200
+	for _, i := range []int{0, 1, 63, 64, 65, n / 2, n - 65, n - 2, n - 1} {
+		d := newTestDeque(n)
+		c.Assert(d.RemoveAt(i), gc.Equals, i)
+		expect := append(sequence(0, i), sequence(i+1, n)...)
+		c.Assert(contents(d), jc.DeepEquals, expect, gc.Commentf("index %d", i))
+		c.Assert(d.Len(), gc.Equals, n-1)
+	}
+}
+
+func (s *accessSuite) TestRemoveAtAll(c *gc.C) {
+	d := newTestDeque(testLen)
+	for d.Len() > 0 {
+		i := d.Len() / 3
+		d.RemoveAt(i)
+	}
+	c.Assert(deque.GetDequeBlocks(d), gc.Equals, 1)
+}
+
+func (s *accessSuite) TestRotate(c *gc.C) {
+	const n = 200
+	for _, r := range []int{0, 1, -1, 70, -70, 130, n, n + 3, -n - 3} {
+		d := newTestDeque(n)
+		d.Rotate(r)
+		k := ((r % n) + n) % n
+		expect := append(sequence(n-k, n), sequence(0, n-k)...)
+		c.Assert(contents(d), jc.DeepEquals, expect, gc.Commentf("rotate %d", r))
+	}
+}
+
+func (s *accessSuite) TestRotateWithMaxLen(c *gc.C) {
+	d := deque.NewOfWithMaxLen[int](3)
+	for i := 0; i < 3; i++ {
+		d.PushBack(i)
+	}
+	d.Rotate(1)
+	c.Assert(contents(d), jc.DeepEquals, []int{2, 0, 1})
+}
+
+func (s *accessSuite) TestClear(c *gc.C) {
+	d := newTestDeque(testLen)
+	d.Clear()
+	c.Assert(d.Len(), gc.Equals, 0)
+	c.Assert(deque.GetDequeBlocks(d), gc.Equals, 1)
+	c.Assert(contents(d), jc.DeepEquals, []int{})
+	d.PushBack(1)
+	d.PushFront(0)
+	c.Assert(contents(d), jc.DeepEquals, []int{0, 1})
+}
@@ -5,7 +5,11 @@ package deque
 
 import "container/list"
 
-// Deque implements an efficient double-ended queue.
+// Deque implements an efficient double-ended queue of
+// arbitrary values. It is equivalent to Of[interface{}].
+type Deque = Of[interface{}]
+
+// Of implements an efficient double-ended queue of values of type T.
 //
 // Internally it is composed of a doubly-linked list (list.List) of
 // blocks. Each block is a slice that holds 0 to blockLen items. The
@@ -23,7 +27,7 @@ import "container/list"
 // blockLen items, instead of for each pushed item. Conversely, fewer
 // memory deallocations are required when popping items. Bookkeeping
 // overhead per item is also reduced.
-type Deque struct {
+type Of[T any] struct {
 	maxLen            int
 	blocks            list.List
 	frontIdx, backIdx int
@@ -38,11 +42,11 @@ type Deque struct {
 const blockLen = 64
 const blockCenter = (blockLen - 1) / 2
 
-type blockT []interface{}
+type blockT[T any] []T
 
 // New returns a new Deque instance.
 func New() *Deque {
-	return NewWithMaxLen(0)
+	return NewOf[interface{}]()
 }
 
 // New returns a new Deque instance which is limited to a certain
@@ -51,17 +55,28 @@ func New() *Deque {
 //
 // A maxLen of 0 means that there is no maximum length limit in place.
 func NewWithMaxLen(maxLen int) *Deque {
-	d := Deque{maxLen: maxLen}
-	d.blocks.PushBack(newBlock())
+	return NewOfWithMaxLen[interface{}](maxLen)
+}
+
+// NewOf returns a new Of[T] instance.
+func NewOf[T any]() *Of[T] {
+	return NewOfWithMaxLen[T](0)
+}
+
+// NewOfWithMaxLen returns a new Of[T] instance which is limited to a
+// certain length, as for NewWithMaxLen.
+func NewOfWithMaxLen[T any](maxLen int) *Of[T] {
+	d := Of[T]{maxLen: maxLen}
+	d.blocks.PushBack(newBlock[T]())
 	d.recenter()
 	return &d
 }
 
-func newBlock() blockT {
-	return make(blockT, blockLen)
+func newBlock[T any]() blockT[T] {
+	return make(blockT[T], blockLen)
 }
 
-func (d *Deque) recenter() {
+func (d *Of[T]) recenter() {
 	// The indexes start crossed at the middle of the block so that
 	// the first push on either side has both indexes pointing at the
 	// first item.
@@ -70,20 +85,20 @@ func (d *Deque) recenter() {
 }
 
 // Len returns the number of items stored in the queue.
-func (d *Deque) Len() int {
+func (d *Of[T]) Len() int {
 	return d.len
 }
 
 // PushBack adds an item to the back of the queue.
-func (d *Deque) PushBack(item interface{}) {
-	var block blockT
+func (d *Of[T]) PushBack(item T) {
+	var block blockT[T]
 	if d.backIdx == blockLen-1 {
 		// The current back block is full so add another.
-		block = newBlock()
+		block = newBlock[T]()
 		d.blocks.PushBack(block)
 		d.backIdx = -1
 	} else {
-		block = d.blocks.Back().Value.(blockT)
+		block = d.blocks.Back().Value.(blockT[T])
 	}
 
 	d.backIdx++
@@ -96,15 +111,15 @@ func (d *Deque) PushBack(item interface{}) {
 }
 
 // PushFront adds an item to the front of the queue.
-func (d *Deque) PushFront(item interface{}) {
-	var block blockT
+func (d *Of[T]) PushFront(item T) {
+	var block blockT[T]
 	if d.frontIdx == 0 {
 		// The current front block is full so add another.
-		block = newBlock()
+		block = newBlock[T]()
 		d.blocks.PushFront(block)
 		d.frontIdx = blockLen
 	} else {
-		block = d.blocks.Front().Value.(blockT)
+		block = d.blocks.Front().Value.(blockT[T])
 	}
 
 	d.frontIdx--
@@ -119,15 +134,16 @@ func (d *Deque) PushFront(item interface{}) {
 // PopBack removes an item from the back of the queue and returns
 // it. The returned flag is true unless there were no items left in
 // the queue.
-func (d *Deque) PopBack() (interface{}, bool) {
+func (d *Of[T]) PopBack() (T, bool) {
+	var zero T
 	if d.len < 1 {
-		return nil, false
+		return zero, false
 	}
 
 	elem := d.blocks.Back()
-	block := elem.Value.(blockT)
+	block := elem.Value.(blockT[T])
 	item := block[d.backIdx]
-	block[d.backIdx] = nil
+	block[d.backIdx] = zero
 	d.backIdx--
 	d.len--
 
@@ -147,15 +163,16 @@ func (d *Deque) PopBack() (interface{}, bool) {
 // PopFront removes an item from the front of the queue and returns
 // it. The returned flag is true unless there were no items left in
 // the queue.
-func (d *Deque) PopFront() (interface{}, bool) {
+func (d *Of[T]) PopFront() (T, bool) {
+	var zero T
 	if d.len < 1 {
-		return nil, false
+		return zero, false
 	}
 
 	elem := d.blocks.Front()
-	block := elem.Value.(blockT)
+	block := elem.Value.(blockT[T])
 	item := block[d.frontIdx]
-	block[d.frontIdx] = nil
+	block[d.frontIdx] = zero
 	d.frontIdx++
 	d.len--
 
@@ -191,6 +191,39 @@ func (s *suite) TestBlockAllocation(c *gc.C) {
 	c.Assert(deque.GetDequeBlocks(s.deque), gc.Equals, 1)
 }
 
+func (s *suite) TestOf(c *gc.C) {
+	d := deque.NewOf[string]()
+	d.PushBack("bar")
+	d.PushFront("foo")
+	d.PushBack("baz")
+	c.Assert(d.Len(), gc.Equals, 3)
+
+	var got []string
+	for {
+		v, ok := d.PopFront()
+		if !ok {
+			break
+		}
+		got = append(got, v)
+	}
+	c.Assert(got, jc.DeepEquals, []string{"foo", "bar", "baz"})
+
+	v, ok := d.PopBack()
+	c.Assert(ok, jc.IsFalse)
+	c.Assert(v, gc.Equals, "")
+}
+
+func (s *suite) TestOfWithMaxLen(c *gc.C) {
+	d := deque.NewOfWithMaxLen[int](2)
+	for i := 0; i < 3; i++ {
+		d.PushBack(i)
+	}
+	c.Assert(d.Len(), gc.Equals, 2)
+	v, ok := d.PopFront()
+	c.Assert(ok, jc.IsTrue)
+	c.Assert(v, gc.Equals, 1)
+}
+
 func (s *suite) checkEmpty(c *gc.C) {
 	c.Assert(s.deque.Len(), gc.Equals, 0)
 
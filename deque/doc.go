@@ -18,6 +18,17 @@
 //    v, ok = d.PopFront()  // v == nil, ok == false
 //    l = d.Len()           // l == 0
 //
+// Deques holding values of a single type can be created with NewOf,
+// avoiding type assertions on the popped values:
+//
+//    d := deque.NewOf[string]()
+//    d.PushBack("foo")
+//    v, ok := d.PopFront() // v == "foo", ok == true
+//
+// Queue wraps a deque for concurrent use, with pops that block
+// until an item is available and a choice of policies for pushes
+// onto a full queue.
+//
 // A discussion of the internals can be found at the top of deque.go.
 //
 package deque
@@ -5,6 +5,6 @@ package deque
 
 // GetDequeBlocks returns the number of internal blocks that the Deque
 // is using.
-func GetDequeBlocks(d *Deque) int {
+func GetDequeBlocks[T any](d *Of[T]) int {
 	return d.blocks.Len()
 }
@@ -0,0 +1,210 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package deque
+
+import (
+	"context"
+	"sync"
+
+	"github.com/juju/errors"
+)
+
+// ErrQueueFull is returned when an item is pushed onto
+// a full Queue with the ReturnError overflow policy.
+var ErrQueueFull = errors.New("queue full")
+
+// ErrQueueClosed is returned when an item is pushed onto a
+// closed Queue, or popped from a closed Queue that is empty.
+var ErrQueueClosed = errors.New("queue closed")
+
+// OverflowPolicy determines what happens when an
+// item is pushed onto a full Queue.
+type OverflowPolicy int
+
+const (
+	// DropOldest drops an item from the opposite end of the
+	// queue to make room for the pushed item, as a Deque
+	// created with NewWithMaxLen does.
+	DropOldest OverflowPolicy = iota
+
+	// DropNewest silently discards the pushed item.
+	DropNewest
+
+	// BlockProducer blocks the push until there is room
+	// in the queue, the queue is closed or the push's
+	// context is done.
+	BlockProducer
+
+	// ReturnError causes the push to fail with ErrQueueFull.
+	ReturnError
+)
+
+// QueueConfig holds the configuration for a Queue.
+type QueueConfig struct {
+	// Cap holds the maximum number of items in the queue.
+	// If it is zero, the queue is unbounded and Overflow
+	// is ignored.
+	Cap int
+
+	// Overflow determines what happens when an item
+	// is pushed onto a full queue.
+	Overflow OverflowPolicy
+}
+
+// Queue is a concurrency-safe double-ended queue, built on Of,
+// whose pop operations block until an item is available. It is
+// suitable for use as a job queue feeding a set of workers.
+type Queue[T any] struct {
+	config QueueConfig
+
+	// mu guards the fields below it.
+	mu     sync.Mutex
+	items  *Of[T]
+	closed bool
+
+	// changed is closed, and set to nil, when items are
+	// added or removed or the queue is closed. It is
+	// created on demand by goroutines that need to wait.
+	changed chan struct{}
+}
+
+// NewQueue returns a new Queue configured according
+// to the given configuration.
+func NewQueue[T any](config QueueConfig) *Queue[T] {
+	return &Queue[T]{
+		config: config,
+		items:  NewOf[T](),
+	}
+}
+
+// Len returns the number of items in the queue.
+func (q *Queue[T]) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.items.Len()
+}
+
+// Cap returns the maximum number of items in the
+// queue, or zero if the queue is unbounded.
+func (q *Queue[T]) Cap() int {
+	return q.config.Cap
+}
+
+// Close closes the queue. Subsequent pushes fail with ErrQueueClosed
+// and any blocked pushes are released with that error. Items already
+// in the queue may still be popped; once the queue is empty, pops
+// fail with ErrQueueClosed. Close always returns nil.
+func (q *Queue[T]) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closed = true
+	q.notify()
+	return nil
+}
+
+// PushBack adds an item to the back of the queue. If the queue is
+// full, the behaviour is determined by the queue's overflow policy.
+// It returns ctx.Err() if the context is done while it is blocked.
+func (q *Queue[T]) PushBack(ctx context.Context, item T) error {
+	return q.push(ctx, item, (*Of[T]).PushBack, (*Of[T]).PopFront)
+}
+
+// PushFront adds an item to the front of the queue. If the queue is
+// full, the behaviour is determined by the queue's overflow policy.
+// It returns ctx.Err() if the context is done while it is blocked.
+func (q *Queue[T]) PushFront(ctx context.Context, item T) error {
+	return q.push(ctx, item, (*Of[T]).PushFront, (*Of[T]).PopBack)
+}
+
+// PopFront removes an item from the front of the queue and returns it,
+// blocking until one is available. It returns ErrQueueClosed if the
+// queue is closed and empty, or ctx.Err() if the context is done first.
+func (q *Queue[T]) PopFront(ctx context.Context) (T, error) {
+	return q.pop(ctx, (*Of[T]).PopFront)
+}
+
+// PopBack removes an item from the back of the queue and returns it,
+// blocking until one is available. It returns ErrQueueClosed if the
+// queue is closed and empty, or ctx.Err() if the context is done first.
+func (q *Queue[T]) PopBack(ctx context.Context) (T, error) {
+	return q.pop(ctx, (*Of[T]).PopBack)
+}
+
+// push implements PushBack and PushFront.
+func (q *Queue[T]) push(ctx context.Context, item T, push func(*Of[T], T), popOpposite func(*Of[T]) (T, bool)) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for {
+		if q.closed {
+			return ErrQueueClosed
+		}
+		if q.config.Cap <= 0 || q.items.Len() < q.config.Cap {
+			break
+		}
+		switch q.config.Overflow {
+		case DropOldest:
+			popOpposite(q.items)
+		case DropNewest:
+			return nil
+		case ReturnError:
+			return ErrQueueFull
+		case BlockProducer:
+			if err := q.wait(ctx); err != nil {
+				return err
+			}
+		default:
+			return errors.Errorf("unknown overflow policy %d", q.config.Overflow)
+		}
+	}
+	push(q.items, item)
+	q.notify()
+	return nil
+}
+
+// pop implements PopBack and PopFront.
+func (q *Queue[T]) pop(ctx context.Context, pop func(*Of[T]) (T, bool)) (T, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for {
+		if item, ok := pop(q.items); ok {
+			q.notify()
+			return item, nil
+		}
+		if q.closed {
+			var zero T
+			return zero, ErrQueueClosed
+		}
+		if err := q.wait(ctx); err != nil {
+			var zero T
+			return zero, err
+		}
+	}
+}
+
+// wait waits until the queue changes or the context is done,
+// returning ctx.Err() in the latter case. It must be called
+// with q.mu held, and returns with it held.
+func (q *Queue[T]) wait(ctx context.Context) error {
+	if q.changed == nil {
+		q.changed = make(chan struct{})
+	}
+	changed := q.changed
+	q.mu.Unlock()
+	defer q.mu.Lock()
+	select {
+	case <-changed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// notify wakes any goroutines waiting for the queue to change.
+// It must be called with q.mu held.
+func (q *Queue[T]) notify() {
+	if q.changed != nil {
+		close(q.changed)
+		q.changed = nil
+	}
+}
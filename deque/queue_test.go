@@ -0,0 +1,210 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package deque_test
+
+import (
+	"context"
+	"time"
+
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/utils/deque"
+)
+
+const (
+	shortWait = 10 * time.Millisecond
+	longWait  = 10 * time.Second
+)
+
+type queueSuite struct {
+	testing.IsolationSuite
+}
+
+var _ = gc.Suite(&queueSuite{})
+
+func (s *queueSuite) TestPushPop(c *gc.C) {
+	q := deque.NewQueue[int](deque.QueueConfig{})
+	ctx := context.Background()
+	c.Assert(q.PushBack(ctx, 1), jc.ErrorIsNil)
+	c.Assert(q.PushBack(ctx, 2), jc.ErrorIsNil)
+	c.Assert(q.PushFront(ctx, 0), jc.ErrorIsNil)
+	c.Assert(q.Len(), gc.Equals, 3)
+	c.Assert(q.Cap(), gc.Equals, 0)
+
+	v, err := q.PopFront(ctx)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(v, gc.Equals, 0)
+	v, err = q.PopBack(ctx)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(v, gc.Equals, 2)
+	v, err = q.PopBack(ctx)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(v, gc.Equals, 1)
+	c.Assert(q.Len(), gc.Equals, 0)
+}
+
+func (s *queueSuite) TestPopBlocks(c *gc.C) {
+	q := deque.NewQueue[string](deque.QueueConfig{})
+	result := make(chan string)
+	go func() {
+		v, err := q.PopFront(context.Background())
+		c.Check(err, jc.ErrorIsNil)
+		result <- v
+	}()
+	select {
+	case v := <-result:
+		c.Fatalf("pop returned %q from empty queue", v)
+	case <-time.After(shortWait):
+	}
+	c.Assert(q.PushBack(context.Background(), "foo"), jc.ErrorIsNil)
+	select {
+	case v := <-result:
+		c.Assert(v, gc.Equals, "foo")
+	case <-time.After(longWait):
+		c.Fatalf("pop did not return")
+	}
+}
+
+func (s *queueSuite) TestPopCanceled(c *gc.C) {
+	q := deque.NewQueue[int](deque.QueueConfig{})
+	ctx, cancel := context.WithCancel(context.Background())
+	go cancel()
+	_, err := q.PopBack(ctx)
+	c.Assert(err, gc.Equals, context.Canceled)
+}
+
+func (s *queueSuite) TestDropOldest(c *gc.C) {
+	q := deque.NewQueue[int](deque.QueueConfig{
+		Cap:      2,
+		Overflow: deque.DropOldest,
+	})
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		c.Assert(q.PushBack(ctx, i), jc.ErrorIsNil)
+	}
+	c.Assert(q.Len(), gc.Equals, 2)
+	assertPops(c, q, 1, 2)
+
+	for i := 0; i < 3; i++ {
+		c.Assert(q.PushFront(ctx, i), jc.ErrorIsNil)
+	}
+	assertPops(c, q, 2, 1)
+}
+
+func (s *queueSuite) TestDropNewest(c *gc.C) {
+	q := deque.NewQueue[int](deque.QueueConfig{
+		Cap:      2,
+		Overflow: deque.DropNewest,
+	})
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		c.Assert(q.PushBack(ctx, i), jc.ErrorIsNil)
+	}
+	assertPops(c, q, 0, 1)
+}
+
+func (s *queueSuite) TestReturnError(c *gc.C) {
+	q := deque.NewQueue[int](deque.QueueConfig{
+		Cap:      1,
+		Overflow: deque.ReturnError,
+	})
+	ctx := context.Background()
+	c.Assert(q.PushBack(ctx, 0), jc.ErrorIsNil)
+	c.Assert(q.PushBack(ctx, 1), gc.Equals, deque.ErrQueueFull)
+	c.Assert(q.PushFront(ctx, 1), gc.Equals, deque.ErrQueueFull)
+	assertPops(c, q, 0)
+}
+
+func (s *queueSuite) TestBlockProducer(c *gc.C) {
+	q := deque.NewQueue[int](deque.QueueConfig{
+		Cap:      1,
+		Overflow: deque.BlockProducer,
+	})
+	ctx := context.Background()
+	c.Assert(q.PushBack(ctx, 0), jc.ErrorIsNil)
+	done := make(chan error)
+	go func() {
+		done <- q.PushBack(ctx, 1)
+	}()
+	select {
+	case err := <-done:
+		c.Fatalf("push returned %v with full queue", err)
+	case <-time.After(shortWait):
+	}
+	assertPops(c, q, 0)
+	select {
+	case err := <-done:
+		c.Assert(err, jc.ErrorIsNil)
+	case <-time.After(longWait):
+		c.Fatalf("push did not return")
+	}
+	assertPops(c, q, 1)
+}
+
+func (s *queueSuite) TestBlockProducerCanceled(c *gc.C) {
+	q := deque.NewQueue[int](deque.QueueConfig{
+		Cap:      1,
+		Overflow: deque.BlockProducer,
+	})
+	c.Assert(q.PushBack(context.Background(), 0), jc.ErrorIsNil)
+	ctx, cancel := context.WithTimeout(context.Background(), shortWait)
+	defer cancel()
+	c.Assert(q.PushBack(ctx, 1), gc.Equals, context.DeadlineExceeded)
+	c.Assert(q.Len(), gc.Equals, 1)
+}
+
+func (s *queueSuite) TestCloseDrains(c *gc.C) {
+	q := deque.NewQueue[int](deque.QueueConfig{})
+	ctx := context.Background()
+	c.Assert(q.PushBack(ctx, 0), jc.ErrorIsNil)
+	c.Assert(q.PushBack(ctx, 1), jc.ErrorIsNil)
+	c.Assert(q.Close(), jc.ErrorIsNil)
+
+	c.Assert(q.PushBack(ctx, 2), gc.Equals, deque.ErrQueueClosed)
+	assertPops(c, q, 0, 1)
+	_, err := q.PopFront(ctx)
+	c.Assert(err, gc.Equals, deque.ErrQueueClosed)
+}
+
+func (s *queueSuite) TestCloseReleasesWaiters(c *gc.C) {
+	q := deque.NewQueue[int](deque.QueueConfig{
+		Cap:      1,
+		Overflow: deque.BlockProducer,
+	})
+	ctx := context.Background()
+	c.Assert(q.PushBack(ctx, 0), jc.ErrorIsNil)
+	pushed := make(chan error)
+	go func() {
+		pushed <- q.PushBack(ctx, 1)
+	}()
+
+	empty := deque.NewQueue[int](deque.QueueConfig{})
+	popped := make(chan error)
+	go func() {
+		_, err := empty.PopFront(ctx)
+		popped <- err
+	}()
+
+	c.Assert(q.Close(), jc.ErrorIsNil)
+	c.Assert(empty.Close(), jc.ErrorIsNil)
+	for _, ch := range []chan error{pushed, popped} {
+		select {
+		case err := <-ch:
+			c.Assert(err, gc.Equals, deque.ErrQueueClosed)
+		case <-time.After(longWait):
+			c.Fatalf("waiter not released")
+		}
+	}
+}
+
+func assertPops(c *gc.C, q *deque.Queue[int], expect ...int) {
+	for _, want := range expect {
+		v, err := q.PopFront(context.Background())
+		c.Assert(err, jc.ErrorIsNil)
+		c.Assert(v, gc.Equals, want)
+	}
+	c.Assert(q.Len(), gc.Equals, 0)
+}
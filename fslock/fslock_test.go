@@ -50,7 +50,15 @@ func (f *fastclock) After(duration time.Duration) <-chan time.Time {
 }
 
 func (f *fastclock) AfterFunc(d time.Duration, af func()) clock.Timer {
-	return time.AfterFunc(d, af)
+	return clock.WallClock.AfterFunc(d, af)
+}
+
+func (f *fastclock) NewTimer(d time.Duration) clock.Timer {
+	return clock.WallClock.NewTimer(d)
+}
+
+func (f *fastclock) NewTicker(d time.Duration) clock.Ticker {
+	return clock.WallClock.NewTicker(d)
 }
 
 func (s *fslockSuite) SetUpTest(c *gc.C) {
@@ -4,56 +4,198 @@
 package utils
 
 import (
+	"container/list"
+	"context"
 	"fmt"
+	"sync"
 )
 
-type empty struct{}
-type limiter chan empty
-
 // Limiter represents a limited resource (eg a semaphore).
+//
+// Units are granted in the order they are requested, so that a large
+// request is not starved by small ones: while any AcquireWait or
+// AcquireContext call is waiting, Acquire and AcquireN fail even if
+// enough units are free to satisfy them.
 type Limiter interface {
 	// Acquire another unit of the resource.
 	// Acquire returns false to indicate there is no more availability,
-	// until another entity calls Release.
+	// until another entity calls Release. It also returns false while
+	// other callers are waiting for units.
 	Acquire() bool
 	// AcquireWait requests a unit of resource, but blocks until one is
-	// available.
+	// available. It panics if the limiter has no units at all, as
+	// none could ever become available.
 	AcquireWait()
 	// Release returns a unit of the resource. Calling Release when there
 	// are no units Acquired is an error.
 	Release() error
+
+	// AcquireN acquires n units of the resource if they are all
+	// available and no other callers are waiting for units, and
+	// reports whether it did so.
+	AcquireN(n int) bool
+	// AcquireContext acquires n units of the resource, blocking until
+	// they are available. If ctx is done first, it returns ctx.Err()
+	// without acquiring anything. It returns an error immediately if
+	// n is greater than the size of the limiter.
+	AcquireContext(ctx context.Context, n int) error
+	// ReleaseN returns n units of the resource. Releasing more units
+	// than have been acquired is an error.
+	ReleaseN(n int) error
+
+	// Available returns the number of units that are not in use.
+	Available() int
+	// InUse returns the number of units that have been acquired
+	// and not released.
+	InUse() int
+}
+
+// limiter implements Limiter. Units are granted to
+// waiters in the order they called AcquireContext,
+// so a large request is not starved by small ones.
+type limiter struct {
+	max int
+
+	// mu guards the fields below it.
+	mu      sync.Mutex
+	inUse   int
+	waiters list.List
+}
+
+// waiter represents a blocked AcquireContext call.
+type waiter struct {
+	n int
+	// ready is closed when the units have been acquired.
+	ready chan struct{}
 }
 
 func NewLimiter(max int) Limiter {
-	return make(limiter, max)
+	return &limiter{max: max}
 }
 
 // Acquire requests some resources that you can return later
 // It returns 'true' if there are resources available, but false if they are
 // not. Callers are responsible for calling Release if this returns true, but
 // should not release if this returns false.
-func (l limiter) Acquire() bool {
-	e := empty{}
-	select {
-	case l <- e:
-		return true
-	default:
-		return false
-	}
+func (l *limiter) Acquire() bool {
+	return l.AcquireN(1)
 }
 
 // AcquireWait waits for the resource to become available before returning.
-func (l limiter) AcquireWait() {
-	e := empty{}
-	l <- e
+func (l *limiter) AcquireWait() {
+	// The context is never done, so the only possible
+	// error is that the limiter is too small.
+	if err := l.AcquireContext(context.Background(), 1); err != nil {
+		panic(fmt.Sprintf("AcquireWait: %v", err))
+	}
 }
 
 // Release returns the resource to the available pool.
-func (l limiter) Release() error {
+func (l *limiter) Release() error {
+	return l.ReleaseN(1)
+}
+
+// AcquireN implements Limiter.AcquireN.
+func (l *limiter) AcquireN(n int) bool {
+	if n < 0 {
+		return false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.max-l.inUse >= n && l.waiters.Len() == 0 {
+		l.inUse += n
+		return true
+	}
+	return false
+}
+
+// AcquireContext implements Limiter.AcquireContext.
+func (l *limiter) AcquireContext(ctx context.Context, n int) error {
+	if n < 0 {
+		return fmt.Errorf("cannot acquire %d units", n)
+	}
+	l.mu.Lock()
+	if n > l.max {
+		l.mu.Unlock()
+		return fmt.Errorf("cannot acquire %d units from a limiter of size %d", n, l.max)
+	}
+	if l.max-l.inUse >= n && l.waiters.Len() == 0 {
+		l.inUse += n
+		l.mu.Unlock()
+		return nil
+	}
+	w := &waiter{
+		n:     n,
+		ready: make(chan struct{}),
+	}
+	elem := l.waiters.PushBack(w)
+	l.mu.Unlock()
+
 	select {
-	case <-l:
+	case <-w.ready:
+		return nil
+	case <-ctx.Done():
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	select {
+	case <-w.ready:
+		// The units were acquired just as the context was done.
 		return nil
 	default:
+	}
+	isFront := l.waiters.Front() == elem
+	l.waiters.Remove(elem)
+	if isFront {
+		// The waiters behind us may now be satisfiable.
+		l.notifyWaiters()
+	}
+	return ctx.Err()
+}
+
+// ReleaseN implements Limiter.ReleaseN.
+func (l *limiter) ReleaseN(n int) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if n < 0 {
+		return fmt.Errorf("cannot release %d units", n)
+	}
+	if n > l.inUse {
 		return fmt.Errorf("Release without an associated Acquire")
 	}
+	l.inUse -= n
+	l.notifyWaiters()
+	return nil
+}
+
+// notifyWaiters grants units to as many waiters as possible, in order.
+// It must be called with l.mu held.
+func (l *limiter) notifyWaiters() {
+	for {
+		elem := l.waiters.Front()
+		if elem == nil {
+			return
+		}
+		w := elem.Value.(*waiter)
+		if l.max-l.inUse < w.n {
+			return
+		}
+		l.inUse += w.n
+		l.waiters.Remove(elem)
+		close(w.ready)
+	}
+}
+
+// Available implements Limiter.Available.
+func (l *limiter) Available() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.max - l.inUse
+}
+
+// InUse implements Limiter.InUse.
+func (l *limiter) InUse() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.inUse
 }
@@ -4,6 +4,7 @@
 package utils_test
 
 import (
+	"context"
 	"fmt"
 	"time"
 
@@ -14,7 +15,10 @@ import (
 	"github.com/juju/utils"
 )
 
-const longWait = 10 * time.Second
+const (
+	shortWait = 50 * time.Millisecond
+	longWait  = 10 * time.Second
+)
 
 type limiterSuite struct {
 	testing.IsolationSuite
@@ -79,3 +83,103 @@ func (*limiterSuite) TestAcquireWaitBlocksUntilRelease(c *gc.C) {
 	}
 	c.Check(calls, gc.DeepEquals, []string{"true", "true", "false", "waited", "false"})
 }
+
+func (*limiterSuite) TestAcquireNAndReleaseN(c *gc.C) {
+	l := utils.NewLimiter(10)
+	c.Check(l.Available(), gc.Equals, 10)
+	c.Check(l.AcquireN(7), jc.IsTrue)
+	c.Check(l.AcquireN(4), jc.IsFalse)
+	c.Check(l.Acquire(), jc.IsTrue)
+	c.Check(l.InUse(), gc.Equals, 8)
+	c.Check(l.Available(), gc.Equals, 2)
+	c.Check(l.ReleaseN(9), gc.ErrorMatches, "Release without an associated Acquire")
+	c.Check(l.ReleaseN(5), gc.IsNil)
+	c.Check(l.InUse(), gc.Equals, 3)
+	c.Check(l.AcquireN(-1), jc.IsFalse)
+	c.Check(l.ReleaseN(-1), gc.ErrorMatches, "cannot release -1 units")
+}
+
+func (*limiterSuite) TestAcquireContextTooMany(c *gc.C) {
+	l := utils.NewLimiter(2)
+	err := l.AcquireContext(context.Background(), 3)
+	c.Check(err, gc.ErrorMatches, "cannot acquire 3 units from a limiter of size 2")
+	c.Check(l.InUse(), gc.Equals, 0)
+}
+
+func (*limiterSuite) TestSizeZero(c *gc.C) {
+	l := utils.NewLimiter(0)
+	c.Check(l.Acquire(), jc.IsFalse)
+	c.Check(l.AcquireWait, gc.PanicMatches, "AcquireWait: cannot acquire 1 units from a limiter of size 0")
+	c.Check(l.InUse(), gc.Equals, 0)
+	c.Check(l.Release(), gc.NotNil)
+}
+
+func (*limiterSuite) TestAcquireContextWaits(c *gc.C) {
+	l := utils.NewLimiter(4)
+	c.Assert(l.AcquireN(3), jc.IsTrue)
+	done := make(chan error)
+	go func() {
+		done <- l.AcquireContext(context.Background(), 2)
+	}()
+	select {
+	case <-done:
+		c.Fatalf("AcquireContext did not block")
+	case <-time.After(shortWait):
+	}
+	// A waiter blocks other acquisitions, even if they would fit.
+	c.Check(l.Acquire(), jc.IsFalse)
+	c.Assert(l.Release(), gc.IsNil)
+	select {
+	case err := <-done:
+		c.Assert(err, jc.ErrorIsNil)
+	case <-time.After(longWait):
+		c.Fatalf("timed out waiting for AcquireContext")
+	}
+	c.Check(l.InUse(), gc.Equals, 4)
+}
+
+func (*limiterSuite) TestAcquireContextCancelled(c *gc.C) {
+	l := utils.NewLimiter(4)
+	c.Assert(l.AcquireN(3), jc.IsTrue)
+	ctx, cancel := context.WithTimeout(context.Background(), shortWait)
+	defer cancel()
+	err := l.AcquireContext(ctx, 2)
+	c.Assert(err, gc.Equals, context.DeadlineExceeded)
+	c.Check(l.InUse(), gc.Equals, 3)
+	// The abandoned request no longer blocks others.
+	c.Check(l.Acquire(), jc.IsTrue)
+}
+
+func (*limiterSuite) TestAcquireContextCancelledUnblocksLaterWaiters(c *gc.C) {
+	l := utils.NewLimiter(4)
+	c.Assert(l.AcquireN(2), jc.IsTrue)
+	ctx, cancel := context.WithCancel(context.Background())
+	big := make(chan error)
+	go func() {
+		big <- l.AcquireContext(ctx, 4)
+	}()
+	// Wait for the big request to start waiting.
+	waitAttempt := utils.AttemptStrategy{
+		Total: longWait,
+		Delay: time.Millisecond,
+	}
+	for a := waitAttempt.Start(); a.Next(); {
+		if !l.Acquire() {
+			break
+		}
+		c.Assert(l.Release(), gc.IsNil)
+	}
+	small := make(chan error)
+	go func() {
+		small <- l.AcquireContext(context.Background(), 2)
+	}()
+	cancel()
+	c.Assert(<-big, gc.Equals, context.Canceled)
+	select {
+	case err := <-small:
+		c.Assert(err, jc.ErrorIsNil)
+	case <-time.After(longWait):
+		c.Fatalf("timed out waiting for AcquireContext")
+	}
+	c.Check(l.InUse(), gc.Equals, 4)
+}
@@ -0,0 +1,180 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package parallel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// GroupConfig holds the configuration for a Group.
+type GroupConfig struct {
+	// Max limits the number of functions running at once.
+	// It must be at least 1.
+	Max int
+
+	// KeepGoing specifies that the Group's context should not be
+	// cancelled when a function returns an error, so that all the
+	// functions are run and all their errors collected. By default,
+	// the first error cancels the context and any functions that
+	// have not yet started are not run.
+	KeepGoing bool
+}
+
+// Group represents a number of functions running concurrently,
+// like Run, that share a context and whose errors are reported
+// along with the task that produced them.
+type Group struct {
+	run       *Run
+	ctx       context.Context
+	cancel    context.CancelFunc
+	keepGoing bool
+
+	// mu guards the fields below it.
+	mu     sync.Mutex
+	next   int
+	failed *TaskError
+	errs   Errors
+}
+
+// NewGroup returns a new Group that runs functions concurrently,
+// passing them a context derived from ctx.
+func NewGroup(ctx context.Context, config GroupConfig) *Group {
+	if config.Max < 1 {
+		panic("parameter max must be >= 1")
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	return &Group{
+		run:       NewRun(config.Max),
+		ctx:       ctx,
+		cancel:    cancel,
+		keepGoing: config.KeepGoing,
+	}
+}
+
+// Context returns the context passed to the functions run by g. Unless
+// KeepGoing was specified, it is cancelled when any function returns
+// an error. It is always cancelled when Wait returns.
+func (g *Group) Context() context.Context {
+	return g.ctx
+}
+
+// Do requests that g run f concurrently, as for Run.Do. Any error
+// returned by f is annotated with the index of the task, counting
+// the calls to Do and DoLabel from zero.
+func (g *Group) Do(f func(ctx context.Context) error) {
+	g.DoLabel("", f)
+}
+
+// DoLabel is like Do except that any error returned by f is
+// annotated with the given label rather than the task index.
+func (g *Group) DoLabel(label string, f func(ctx context.Context) error) {
+	g.mu.Lock()
+	task := TaskError{
+		Index: g.next,
+		Label: label,
+	}
+	g.next++
+	g.mu.Unlock()
+	g.run.Do(func() error {
+		// Don't start any more work once the context is done.
+		err := g.ctx.Err()
+		if err == nil {
+			err = f(g.ctx)
+		}
+		if err != nil {
+			task.Err = err
+			g.addError(&task)
+		}
+		return nil
+	})
+}
+
+// addError records the error from a task, cancelling
+// the context if it is the first failure.
+func (g *Group) addError(err *TaskError) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.errs = append(g.errs, err)
+	if g.failed == nil && !g.keepGoing && g.ctx.Err() == nil {
+		g.failed = err
+		g.cancel()
+	}
+}
+
+// Wait waits for all the functions to complete, as for Run.Wait. If
+// any errors were encountered, it returns an Errors value holding a
+// *TaskError for each failed task, ordered by task index. When the
+// context was cancelled because of a failure, the cancellation errors
+// of the other tasks are omitted, so the first element is always the
+// error that caused the cancellation.
+func (g *Group) Wait() error {
+	g.run.Wait()
+	g.cancel()
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	var errs Errors
+	if g.failed != nil {
+		errs = append(errs, g.failed)
+	}
+	for _, err := range g.errs {
+		err := err.(*TaskError)
+		if g.failed != nil && (err == g.failed || errors.Is(err.Err, context.Canceled)) {
+			continue
+		}
+		errs = append(errs, err)
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	if g.failed != nil {
+		// Keep the failure first.
+		sortTaskErrors(errs[1:])
+	} else {
+		sortTaskErrors(errs)
+	}
+	return errs
+}
+
+// sortTaskErrors sorts a slice of *TaskError by task index.
+func sortTaskErrors(errs Errors) {
+	sort.SliceStable(errs, func(i, j int) bool {
+		return errs[i].(*TaskError).Index < errs[j].(*TaskError).Index
+	})
+}
+
+// TaskError holds an error returned by a function run by a Group.
+type TaskError struct {
+	// Index holds the index of the task, counting the
+	// calls to Group.Do and Group.DoLabel from zero.
+	Index int
+
+	// Label holds the label passed to Group.DoLabel, if any.
+	Label string
+
+	// Err holds the error returned by the task.
+	Err error
+}
+
+// Error implements error.
+func (e *TaskError) Error() string {
+	if e.Label != "" {
+		return fmt.Sprintf("%s: %v", e.Label, e.Err)
+	}
+	return fmt.Sprintf("task %d: %v", e.Index, e.Err)
+}
+
+// Cause returns the error returned by the task,
+// for use by github.com/juju/errors.Cause.
+func (e *TaskError) Cause() error {
+	return e.Err
+}
+
+// Unwrap returns the error returned by the task.
+func (e *TaskError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,112 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package parallel_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/utils/parallel"
+)
+
+type groupSuite struct {
+	testing.IsolationSuite
+}
+
+var _ = gc.Suite(&groupSuite{})
+
+func (*groupSuite) TestSuccess(c *gc.C) {
+	g := parallel.NewGroup(context.Background(), parallel.GroupConfig{Max: 3})
+	var count int32
+	for i := 0; i < 10; i++ {
+		g.Do(func(ctx context.Context) error {
+			atomic.AddInt32(&count, 1)
+			return nil
+		})
+	}
+	c.Assert(g.Wait(), jc.ErrorIsNil)
+	c.Assert(count, gc.Equals, int32(10))
+	c.Assert(g.Context().Err(), gc.Equals, context.Canceled)
+}
+
+func (*groupSuite) TestFailFast(c *gc.C) {
+	g := parallel.NewGroup(context.Background(), parallel.GroupConfig{Max: 2})
+	started := make(chan struct{})
+	g.DoLabel("waiter", func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	<-started
+	g.DoLabel("deploy", func(ctx context.Context) error {
+		return errors.New("boom")
+	})
+	var ran int32
+	for i := 0; i < 5; i++ {
+		g.Do(func(ctx context.Context) error {
+			atomic.AddInt32(&ran, 1)
+			return nil
+		})
+	}
+	err := g.Wait()
+	c.Assert(err, gc.ErrorMatches, "deploy: boom")
+	errs := err.(parallel.Errors)
+	c.Assert(errs, gc.HasLen, 1)
+	taskErr := errs[0].(*parallel.TaskError)
+	c.Assert(taskErr.Index, gc.Equals, 1)
+	c.Assert(taskErr.Label, gc.Equals, "deploy")
+	c.Assert(taskErr.Cause(), gc.ErrorMatches, "boom")
+	// The tasks started after the failure were not run.
+	c.Assert(ran, gc.Equals, int32(0))
+}
+
+func (*groupSuite) TestKeepGoing(c *gc.C) {
+	g := parallel.NewGroup(context.Background(), parallel.GroupConfig{
+		Max:       3,
+		KeepGoing: true,
+	})
+	for i := 0; i < 10; i++ {
+		i := i
+		g.Do(func(ctx context.Context) error {
+			c.Check(ctx.Err(), gc.IsNil)
+			if i%3 == 0 {
+				return intError(i)
+			}
+			return nil
+		})
+	}
+	err := g.Wait()
+	c.Assert(err, gc.ErrorMatches, `task 0: error \(and 3 more\)`)
+	errs := err.(parallel.Errors)
+	c.Assert(errs, gc.HasLen, 4)
+	for i, err := range errs {
+		taskErr := err.(*parallel.TaskError)
+		c.Check(taskErr.Index, gc.Equals, i*3)
+		c.Check(taskErr.Err, gc.Equals, intError(i*3))
+	}
+}
+
+func (*groupSuite) TestParentCancelled(c *gc.C) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	g := parallel.NewGroup(ctx, parallel.GroupConfig{Max: 1})
+	g.Do(func(ctx context.Context) error {
+		c.Errorf("function unexpectedly called")
+		return nil
+	})
+	err := g.Wait()
+	c.Assert(err, gc.ErrorMatches, "task 0: context canceled")
+	c.Assert(errors.Is(err.(parallel.Errors)[0], context.Canceled), jc.IsTrue)
+}
+
+func (*groupSuite) TestZeroMaxPanics(c *gc.C) {
+	c.Assert(func() {
+		parallel.NewGroup(context.Background(), parallel.GroupConfig{})
+	}, gc.PanicMatches, "parameter max must be >= 1")
+}
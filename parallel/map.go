@@ -0,0 +1,101 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package parallel
+
+import (
+	"context"
+	"sync"
+)
+
+// MapConfig holds the configuration for Map and ForEach.
+type MapConfig struct {
+	// Max limits the number of inputs processed at once.
+	// It must be at least 1.
+	Max int
+
+	// FailFast specifies that processing should stop at the first
+	// error: the context passed to the function is cancelled and
+	// inputs that have not yet been started are not processed.
+	FailFast bool
+
+	// Progress, if non-nil, is called after each input has been
+	// processed, successfully or not, with the number of inputs
+	// processed so far and the total number of inputs. It is
+	// never called concurrently.
+	Progress func(done, total int)
+}
+
+// Map calls f concurrently for each of the inputs, with no more than
+// config.Max calls running at once, and returns the results in input
+// order. If any of the calls failed, it also returns a slice holding
+// the error for each input, which is nil for those that succeeded;
+// otherwise the returned error slice is nil.
+//
+// When ctx is cancelled, or when an input fails and config.FailFast
+// is set, the context passed to f is cancelled and inputs that have
+// not yet been started are not processed; their error is the error
+// of the context.
+func Map[In, Out any](ctx context.Context, inputs []In, config MapConfig, f func(ctx context.Context, index int, in In) (Out, error)) ([]Out, []error) {
+	if config.Max < 1 {
+		panic("parameter max must be >= 1")
+	}
+	g := NewGroup(ctx, GroupConfig{
+		Max:       config.Max,
+		KeepGoing: !config.FailFast,
+	})
+	var (
+		outs = make([]Out, len(inputs))
+		errs = make([]error, len(inputs))
+
+		mu      sync.Mutex
+		started = make([]bool, len(inputs))
+		done    int
+		failed  bool
+	)
+	for i, in := range inputs {
+		i, in := i, in
+		g.Do(func(ctx context.Context) error {
+			mu.Lock()
+			started[i] = true
+			mu.Unlock()
+			out, err := f(ctx, i, in)
+
+			mu.Lock()
+			defer mu.Unlock()
+			outs[i], errs[i] = out, err
+			if err != nil {
+				failed = true
+			}
+			done++
+			if config.Progress != nil {
+				config.Progress(done, len(inputs))
+			}
+			return err
+		})
+	}
+	g.Wait()
+
+	// Record an error for each input that was never started.
+	for i := range inputs {
+		if !started[i] {
+			errs[i] = ctx.Err()
+			if errs[i] == nil {
+				errs[i] = context.Canceled
+			}
+			failed = true
+		}
+	}
+	if !failed {
+		errs = nil
+	}
+	return outs, errs
+}
+
+// ForEach is like Map for functions that return no value.
+func ForEach[In any](ctx context.Context, inputs []In, config MapConfig, f func(ctx context.Context, index int, in In) error) []error {
+	_, errs := Map(ctx, inputs, config, func(ctx context.Context, index int, in In) (struct{}, error) {
+		return struct{}{}, f(ctx, index, in)
+	})
+	return errs
+}
@@ -0,0 +1,136 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package parallel_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/utils/parallel"
+)
+
+type mapSuite struct {
+	testing.IsolationSuite
+}
+
+var _ = gc.Suite(&mapSuite{})
+
+func (*mapSuite) TestMapOrdered(c *gc.C) {
+	inputs := []int{5, 4, 3, 2, 1}
+	var (
+		mu       sync.Mutex
+		progress []int
+	)
+	outs, errs := parallel.Map(context.Background(), inputs, parallel.MapConfig{
+		Max: 3,
+		Progress: func(done, total int) {
+			mu.Lock()
+			defer mu.Unlock()
+			c.Check(total, gc.Equals, len(inputs))
+			progress = append(progress, done)
+		},
+	}, func(ctx context.Context, i int, in int) (string, error) {
+		// Finish in a different order from the inputs.
+		time.Sleep(time.Duration(in) * time.Millisecond)
+		return fmt.Sprintf("%d:%d", i, in), nil
+	})
+	c.Assert(errs, gc.IsNil)
+	c.Assert(outs, jc.DeepEquals, []string{"0:5", "1:4", "2:3", "3:2", "4:1"})
+	c.Assert(progress, jc.DeepEquals, []int{1, 2, 3, 4, 5})
+}
+
+func (*mapSuite) TestMapMaxConcurrency(c *gc.C) {
+	var (
+		mu      sync.Mutex
+		running int
+		max     int
+	)
+	inputs := make([]int, 20)
+	_, errs := parallel.Map(context.Background(), inputs, parallel.MapConfig{Max: 4}, func(ctx context.Context, i int, in int) (int, error) {
+		mu.Lock()
+		running++
+		if running > max {
+			max = running
+		}
+		mu.Unlock()
+		time.Sleep(5 * time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return in, nil
+	})
+	c.Assert(errs, gc.IsNil)
+	c.Assert(max <= 4, jc.IsTrue, gc.Commentf("max %d", max))
+}
+
+func (*mapSuite) TestMapErrors(c *gc.C) {
+	outs, errs := parallel.Map(context.Background(), []int{1, 2, 3, 4}, parallel.MapConfig{Max: 2}, func(ctx context.Context, i int, in int) (int, error) {
+		if in%2 == 0 {
+			return 0, fmt.Errorf("even %d", in)
+		}
+		return in * 10, nil
+	})
+	c.Assert(outs, jc.DeepEquals, []int{10, 0, 30, 0})
+	c.Assert(errs, gc.HasLen, 4)
+	c.Assert(errs[0], gc.IsNil)
+	c.Assert(errs[1], gc.ErrorMatches, "even 2")
+	c.Assert(errs[2], gc.IsNil)
+	c.Assert(errs[3], gc.ErrorMatches, "even 4")
+}
+
+func (*mapSuite) TestForEachFailFast(c *gc.C) {
+	var (
+		mu  sync.Mutex
+		ran []int
+	)
+	errs := parallel.ForEach(context.Background(), []string{"a", "b", "c", "d"}, parallel.MapConfig{
+		Max:      1,
+		FailFast: true,
+	}, func(ctx context.Context, i int, in string) error {
+		mu.Lock()
+		ran = append(ran, i)
+		mu.Unlock()
+		if in == "b" {
+			return errors.New("bad b")
+		}
+		return nil
+	})
+	c.Assert(ran, jc.DeepEquals, []int{0, 1})
+	c.Assert(errs, gc.HasLen, 4)
+	c.Assert(errs[0], gc.IsNil)
+	c.Assert(errs[1], gc.ErrorMatches, "bad b")
+	c.Assert(errs[2], gc.Equals, context.Canceled)
+	c.Assert(errs[3], gc.Equals, context.Canceled)
+}
+
+func (*mapSuite) TestForEachCancelled(c *gc.C) {
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := parallel.ForEach(ctx, []int{0, 1, 2}, parallel.MapConfig{Max: 1}, func(ctx context.Context, i int, in int) error {
+		if i == 1 {
+			cancel()
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		return nil
+	})
+	c.Assert(errs, gc.HasLen, 3)
+	c.Assert(errs[0], gc.IsNil)
+	c.Assert(errs[1], gc.Equals, context.Canceled)
+	c.Assert(errs[2], gc.Equals, context.Canceled)
+}
+
+func (*mapSuite) TestForEachEmpty(c *gc.C) {
+	errs := parallel.ForEach(context.Background(), []int(nil), parallel.MapConfig{Max: 1}, func(ctx context.Context, i int, in int) error {
+		c.Errorf("function unexpectedly called")
+		return nil
+	})
+	c.Assert(errs, gc.IsNil)
+}
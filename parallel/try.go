@@ -7,8 +7,11 @@ import (
 	"errors"
 	"io"
 	"sync"
+	"time"
 
 	"launchpad.net/tomb"
+
+	"github.com/juju/utils/clock"
 )
 
 var (
@@ -23,11 +26,63 @@ type Try struct {
 	closeMutex    sync.Mutex
 	close         chan struct{}
 	limiter       chan struct{}
-	start         chan func()
+	start         chan func(index int)
 	result        chan result
 	combineErrors func(err0, err1 error) error
 	maxParallel   int
+	stagger       time.Duration
+	clock         clock.Clock
 	endResult     io.Closer
+
+	// attemptsMutex guards attempts.
+	attemptsMutex sync.Mutex
+	attempts      []AttemptReport
+}
+
+// TryConfig holds the configuration for a Try.
+type TryConfig struct {
+	// MaxParallel, if positive, limits the number
+	// of concurrently running functions.
+	MaxParallel int
+
+	// CombineErrors is used to determine the error returned by
+	// Result, as described in NewTry. If it is nil, the last
+	// encountered error is chosen.
+	CombineErrors func(err0, err1 error) error
+
+	// Stagger, if positive, staggers the start of the functions
+	// in the style of "happy eyeballs": after a function has been
+	// started, the next one is not started until either Stagger
+	// has elapsed or a running function has failed.
+	Stagger time.Duration
+
+	// Clock is used to stagger the functions and to measure their
+	// latency. If it is nil, clock.WallClock is used.
+	Clock clock.Clock
+}
+
+// AttemptReport describes a function started by a Try.
+type AttemptReport struct {
+	// Index holds the index of the attempt, counting
+	// the functions started by the Try from zero.
+	Index int
+
+	// Started holds the time that the attempt was started.
+	Started time.Time
+
+	// Done reports whether the function has returned.
+	Done bool
+
+	// Latency holds the time the function took
+	// to return, if it has returned.
+	Latency time.Duration
+
+	// Err holds the error returned by the function, if any.
+	Err error
+
+	// Won reports whether the result of the function
+	// is the result of the Try.
+	Won bool
 }
 
 // NewTry returns an object that runs functions concurrently until one
@@ -41,15 +96,29 @@ type Try struct {
 // returned by combineErrors. If combineErrors is nil, the last
 // encountered error is chosen.
 func NewTry(maxParallel int, combineErrors func(err0, err1 error) error) *Try {
-	if combineErrors == nil {
-		combineErrors = chooseLastError
+	return NewTryWithConfig(TryConfig{
+		MaxParallel:   maxParallel,
+		CombineErrors: combineErrors,
+	})
+}
+
+// NewTryWithConfig is like NewTry except that it takes its parameters
+// from config, allowing the start of the functions to be staggered.
+func NewTryWithConfig(config TryConfig) *Try {
+	if config.CombineErrors == nil {
+		config.CombineErrors = chooseLastError
+	}
+	if config.Clock == nil {
+		config.Clock = clock.WallClock
 	}
 	t := &Try{
-		combineErrors: combineErrors,
-		maxParallel:   maxParallel,
+		combineErrors: config.CombineErrors,
+		maxParallel:   config.MaxParallel,
+		stagger:       config.Stagger,
+		clock:         config.Clock,
 		close:         make(chan struct{}, 1),
 		result:        make(chan result),
-		start:         make(chan func()),
+		start:         make(chan func(index int)),
 	}
 	if t.maxParallel > 0 {
 		t.limiter = make(chan struct{}, t.maxParallel)
@@ -71,24 +140,39 @@ func chooseLastError(err0, err1 error) error {
 }
 
 type result struct {
-	val io.Closer
-	err error
+	index int
+	val   io.Closer
+	err   error
 }
 
 func (t *Try) loop() (io.Closer, error) {
 	var err error
 	close := t.close
 	nrunning := 0
+	// When staggering, start is set to nil until
+	// it's time to start the next function.
+	start := t.start
+	var staggered <-chan time.Time
 	for {
 		select {
-		case f := <-t.start:
+		case f := <-start:
 			nrunning++
-			go f()
+			go f(t.addAttempt())
+			if t.stagger > 0 {
+				start = nil
+				staggered = t.clock.After(t.stagger)
+			}
+		case <-staggered:
+			start, staggered = t.start, nil
 		case r := <-t.result:
 			if r.err == nil {
+				t.setWon(r.index)
 				return r.val, r.err
 			}
 			err = t.combineErrors(err, r.err)
+			// Start the next function straight
+			// away after a failure.
+			start, staggered = t.start, nil
 			nrunning--
 			if close == nil && nrunning == 0 {
 				return nil, err
@@ -108,7 +192,8 @@ func (t *Try) loop() (io.Closer, error) {
 }
 
 // Start requests the given function to be started, waiting until there
-// are less than maxParallel functions running if necessary. It returns
+// are less than maxParallel functions running, and for the stagger
+// delay to elapse, if necessary. It returns
 // an error if the function has not been started (ErrClosed if the Try
 // has been closed, and ErrStopped if the try is finishing).
 //
@@ -131,15 +216,16 @@ func (t *Try) Start(try func(stop <-chan struct{}) (io.Closer, error)) error {
 		}
 	}
 	dying := t.tomb.Dying()
-	f := func() {
+	f := func(index int) {
 		val, err := try(dying)
+		t.attemptDone(index, err)
 		if t.limiter != nil {
 			// Signal availability slot is now free.
 			t.limiter <- struct{}{}
 		}
 		// Deliver result.
 		select {
-		case t.result <- result{val, err}:
+		case t.result <- result{index, val, err}:
 		case <-dying:
 			if err == nil {
 				val.Close()
@@ -156,6 +242,45 @@ func (t *Try) Start(try func(stop <-chan struct{}) (io.Closer, error)) error {
 	}
 }
 
+// addAttempt records the start of an attempt
+// and returns its index.
+func (t *Try) addAttempt() int {
+	t.attemptsMutex.Lock()
+	defer t.attemptsMutex.Unlock()
+	index := len(t.attempts)
+	t.attempts = append(t.attempts, AttemptReport{
+		Index:   index,
+		Started: t.clock.Now(),
+	})
+	return index
+}
+
+// attemptDone records the outcome of an attempt.
+func (t *Try) attemptDone(index int, err error) {
+	t.attemptsMutex.Lock()
+	defer t.attemptsMutex.Unlock()
+	a := &t.attempts[index]
+	a.Done = true
+	a.Latency = t.clock.Now().Sub(a.Started)
+	a.Err = err
+}
+
+// setWon records that the given attempt provided the result.
+func (t *Try) setWon(index int) {
+	t.attemptsMutex.Lock()
+	defer t.attemptsMutex.Unlock()
+	t.attempts[index].Won = true
+}
+
+// Attempts returns a report of every function started by the Try so
+// far, in the order they were started. Functions that are still
+// running are reported with Done set to false.
+func (t *Try) Attempts() []AttemptReport {
+	t.attemptsMutex.Lock()
+	defer t.attemptsMutex.Unlock()
+	return append([]AttemptReport(nil), t.attempts...)
+}
+
 // Close closes the Try. No more functions will be started
 // if Start is called, and the Try will terminate when all
 // outstanding functions have completed (or earlier
@@ -15,6 +15,7 @@ import (
 	jc "github.com/juju/testing/checkers"
 	gc "gopkg.in/check.v1"
 
+	"github.com/juju/utils/clock/testclock"
 	"github.com/juju/utils/parallel"
 )
 
@@ -383,3 +384,98 @@ func (*trySuite) TestEverything(c *gc.C) {
 	}
 	c.Assert(err, gc.IsNil)
 }
+
+func (*trySuite) TestStagger(c *gc.C) {
+	clock := testclock.NewClock(time.Time{})
+	try := parallel.NewTryWithConfig(parallel.TryConfig{
+		Stagger: time.Second,
+		Clock:   clock,
+	})
+	begin := make(chan struct{})
+	started := make(chan int)
+	go func() {
+		for i := 0; i < 2; i++ {
+			i := i
+			err := try.Start(func(<-chan struct{}) (io.Closer, error) {
+				started <- i
+				<-begin
+				return result(fmt.Sprint("result ", i)), nil
+			})
+			c.Check(err, gc.IsNil)
+		}
+	}()
+	c.Assert(receiveInt(c, started), gc.Equals, 0)
+
+	// The second attempt is not started until the stagger delay has elapsed.
+	select {
+	case <-started:
+		c.Fatalf("attempt started too early")
+	case <-time.After(shortWait):
+	}
+	err := clock.WaitAdvance(time.Second, longWait, 1)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(receiveInt(c, started), gc.Equals, 1)
+
+	begin <- struct{}{}
+	val, err := try.Result()
+	c.Assert(err, gc.IsNil)
+	attempts := try.Attempts()
+	c.Assert(attempts, gc.HasLen, 2)
+	c.Assert(attempts[0].Index, gc.Equals, 0)
+	c.Assert(attempts[1].Index, gc.Equals, 1)
+	c.Assert(attempts[1].Started.Sub(attempts[0].Started), gc.Equals, time.Second)
+	winner := 0
+	if val == result("result 1") {
+		winner = 1
+	}
+	c.Assert(attempts[winner].Won, jc.IsTrue)
+	c.Assert(attempts[winner].Done, jc.IsTrue)
+	c.Assert(attempts[1-winner].Won, jc.IsFalse)
+	c.Assert(attempts[1-winner].Done, jc.IsFalse)
+	begin <- struct{}{}
+}
+
+func (*trySuite) TestStaggerStartsNextOnFailure(c *gc.C) {
+	clock := testclock.NewClock(time.Time{})
+	try := parallel.NewTryWithConfig(parallel.TryConfig{
+		Stagger: time.Hour,
+		Clock:   clock,
+	})
+	expectErr := errors.New("foo")
+	err := try.Start(func(<-chan struct{}) (io.Closer, error) {
+		clock.Advance(time.Second)
+		return nil, expectErr
+	})
+	c.Assert(err, gc.IsNil)
+	// The failure of the first attempt allows the
+	// second to start without waiting for the clock.
+	err = try.Start(tryFunc(0, result("hello"), nil))
+	c.Assert(err, gc.IsNil)
+	val, err := try.Result()
+	c.Assert(err, gc.IsNil)
+	c.Assert(val, gc.Equals, result("hello"))
+
+	attempts := try.Attempts()
+	c.Assert(attempts, jc.DeepEquals, []parallel.AttemptReport{{
+		Index:   0,
+		Started: time.Time{},
+		Done:    true,
+		Latency: time.Second,
+		Err:     expectErr,
+	}, {
+		Index:   1,
+		Started: time.Time{}.Add(time.Second),
+		Done:    true,
+		Won:     true,
+	}})
+}
+
+func receiveInt(c *gc.C, ch <-chan int) int {
+	select {
+	case i := <-ch:
+		return i
+	case <-time.After(longWait):
+		c.Fatalf("timed out waiting for value")
+	}
+	panic("unreachable")
+}
@@ -0,0 +1,275 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+// Package ratelimit provides a token-bucket rate limiter.
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/juju/utils/clock"
+)
+
+// ErrWouldExceedDeadline is returned by Wait and WaitN when the
+// tokens would not become available before the context deadline.
+var ErrWouldExceedDeadline = errors.New("rate limit wait would exceed context deadline")
+
+// Config holds the configuration for a Bucket.
+type Config struct {
+	// Rate holds the number of tokens added
+	// to the bucket each second.
+	Rate float64
+
+	// Burst holds the capacity of the bucket, which is the
+	// maximum number of tokens that can be taken at once.
+	// The bucket starts full.
+	Burst int
+
+	// Clock is used to measure the passing of time.
+	// If it is nil, clock.WallClock is used.
+	Clock clock.Clock
+}
+
+// Bucket is a token-bucket rate limiter. Tokens are added at a
+// steady rate up to the capacity of the bucket, and each event
+// takes one or more tokens, so that events happen at the rate on
+// average while allowing bursts. Methods on a Bucket may be
+// called concurrently.
+type Bucket struct {
+	clock clock.Clock
+
+	// mu guards the fields below it.
+	mu     sync.Mutex
+	rate   float64
+	burst  int
+	tokens float64
+	last   time.Time
+}
+
+// NewBucket returns a new Bucket with the given configuration.
+func NewBucket(config Config) *Bucket {
+	if config.Clock == nil {
+		config.Clock = clock.WallClock
+	}
+	return &Bucket{
+		clock:  config.Clock,
+		rate:   config.Rate,
+		burst:  config.Burst,
+		tokens: float64(config.Burst),
+		last:   config.Clock.Now(),
+	}
+}
+
+// Rate returns the number of tokens added to the bucket each second.
+func (b *Bucket) Rate() float64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.rate
+}
+
+// SetRate changes the number of tokens added to the bucket each
+// second. Tokens already accumulated are kept; outstanding
+// reservations are not affected.
+func (b *Bucket) SetRate(rate float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advance(b.clock.Now())
+	b.rate = rate
+}
+
+// Burst returns the capacity of the bucket.
+func (b *Bucket) Burst() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.burst
+}
+
+// SetBurst changes the capacity of the bucket, discarding
+// any tokens in excess of the new capacity.
+func (b *Bucket) SetBurst(burst int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advance(b.clock.Now())
+	b.burst = burst
+	if b.tokens > float64(burst) {
+		b.tokens = float64(burst)
+	}
+}
+
+// Available returns the number of tokens currently in the bucket.
+// It is negative when tokens have been reserved in advance.
+func (b *Bucket) Available() float64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advance(b.clock.Now())
+	return b.tokens
+}
+
+// Allow is shorthand for AllowN(1).
+func (b *Bucket) Allow() bool {
+	return b.AllowN(1)
+}
+
+// AllowN takes n tokens from the bucket if they are
+// available now, and reports whether it did so. It
+// returns false if n is negative.
+func (b *Bucket) AllowN(n int) bool {
+	if n < 0 {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advance(b.clock.Now())
+	if b.tokens < float64(n) {
+		return false
+	}
+	b.tokens -= float64(n)
+	return true
+}
+
+// Wait is shorthand for WaitN(ctx, 1).
+func (b *Bucket) Wait(ctx context.Context) error {
+	return b.WaitN(ctx, 1)
+}
+
+// WaitN blocks until n tokens can be taken from the bucket, and takes
+// them. If the context is done first, or its deadline would pass
+// before the tokens are available, WaitN returns an error and no
+// tokens are taken.
+func (b *Bucket) WaitN(ctx context.Context, n int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r := b.ReserveN(n)
+	if !r.OK() {
+		return fmt.Errorf("cannot take %d tokens from bucket with burst %d and rate %g", n, b.Burst(), b.Rate())
+	}
+	delay := r.Delay()
+	if delay <= 0 {
+		return nil
+	}
+	if deadline, ok := ctx.Deadline(); ok && deadline.Before(r.TimeToAct()) {
+		r.Cancel()
+		return ErrWouldExceedDeadline
+	}
+	select {
+	case <-b.clock.After(delay):
+		return nil
+	case <-ctx.Done():
+		r.Cancel()
+		return ctx.Err()
+	}
+}
+
+// Reserve is shorthand for ReserveN(1).
+func (b *Bucket) Reserve() *Reservation {
+	return b.ReserveN(1)
+}
+
+// ReserveN takes n tokens from the bucket, possibly in advance of
+// their availability, and returns a Reservation that tells the
+// caller how long to wait before acting. If the tokens can never
+// become available, because n is negative or exceeds the capacity of
+// the bucket, or the rate is not positive, the returned Reservation
+// is not OK and no tokens are taken.
+func (b *Bucket) ReserveN(n int) *Reservation {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.clock.Now()
+	b.advance(now)
+	r := &Reservation{
+		bucket: b,
+		tokens: n,
+	}
+	if n < 0 || n > b.burst {
+		return r
+	}
+	var wait time.Duration
+	if missing := float64(n) - b.tokens; missing > 0 {
+		if b.rate <= 0 {
+			return r
+		}
+		wait = time.Duration(missing / b.rate * float64(time.Second))
+	}
+	b.tokens -= float64(n)
+	r.ok = true
+	r.timeToAct = now.Add(wait)
+	return r
+}
+
+// advance adds the tokens accumulated since the last call.
+// It must be called with b.mu held.
+func (b *Bucket) advance(now time.Time) {
+	if elapsed := now.Sub(b.last); elapsed > 0 && b.rate > 0 {
+		b.tokens += elapsed.Seconds() * b.rate
+		if b.tokens > float64(b.burst) {
+			b.tokens = float64(b.burst)
+		}
+	}
+	b.last = now
+}
+
+// Reservation holds tokens reserved from a Bucket by Reserve or ReserveN.
+type Reservation struct {
+	bucket    *Bucket
+	tokens    int
+	ok        bool
+	timeToAct time.Time
+
+	// cancelled is guarded by bucket.mu.
+	cancelled bool
+}
+
+// OK reports whether the tokens were reserved. If it returns false,
+// the tokens can never become available and Delay returns zero.
+func (r *Reservation) OK() bool {
+	return r.ok
+}
+
+// TimeToAct returns the time at which the reserved tokens
+// are available.
+func (r *Reservation) TimeToAct() time.Time {
+	return r.timeToAct
+}
+
+// Delay returns how long the caller must wait before acting on the
+// reservation. It returns zero if the tokens are already available
+// or the reservation is not OK.
+func (r *Reservation) Delay() time.Duration {
+	if !r.ok {
+		return 0
+	}
+	delay := r.timeToAct.Sub(r.bucket.clock.Now())
+	if delay < 0 {
+		return 0
+	}
+	return delay
+}
+
+// Cancel indicates that the reservation will not be acted on,
+// returning its tokens to the bucket so that later reservations
+// are not delayed by it. It does nothing if the reservation is
+// not OK, has already been cancelled, or its time to act has
+// passed.
+func (r *Reservation) Cancel() {
+	if !r.ok {
+		return
+	}
+	b := r.bucket
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.clock.Now()
+	if r.cancelled || !now.Before(r.timeToAct) {
+		return
+	}
+	r.cancelled = true
+	b.advance(now)
+	b.tokens += float64(r.tokens)
+	if b.tokens > float64(b.burst) {
+		b.tokens = float64(b.burst)
+	}
+}
@@ -0,0 +1,177 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package ratelimit_test
+
+import (
+	"context"
+	"time"
+
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/utils/clock"
+	"github.com/juju/utils/clock/testclock"
+	"github.com/juju/utils/ratelimit"
+)
+
+const longWait = 10 * time.Second
+
+type bucketSuite struct {
+	testing.IsolationSuite
+	clock *testclock.Clock
+}
+
+var _ = gc.Suite(&bucketSuite{})
+
+func (s *bucketSuite) SetUpTest(c *gc.C) {
+	s.IsolationSuite.SetUpTest(c)
+	s.clock = testclock.NewClock(time.Time{})
+}
+
+func (s *bucketSuite) newBucket(rate float64, burst int) *ratelimit.Bucket {
+	return ratelimit.NewBucket(ratelimit.Config{
+		Rate:  rate,
+		Burst: burst,
+		Clock: s.clock,
+	})
+}
+
+func (s *bucketSuite) TestAllow(c *gc.C) {
+	b := s.newBucket(2, 3)
+	c.Assert(b.Allow(), jc.IsTrue)
+	c.Assert(b.AllowN(2), jc.IsTrue)
+	c.Assert(b.Allow(), jc.IsFalse)
+
+	s.clock.Advance(500 * time.Millisecond)
+	c.Assert(b.Allow(), jc.IsTrue)
+	c.Assert(b.Allow(), jc.IsFalse)
+
+	// The bucket never holds more than the burst.
+	s.clock.Advance(time.Hour)
+	c.Assert(b.Available(), gc.Equals, 3.0)
+	c.Assert(b.AllowN(4), jc.IsFalse)
+}
+
+func (s *bucketSuite) TestReserve(c *gc.C) {
+	b := s.newBucket(10, 1)
+	r := b.Reserve()
+	c.Assert(r.OK(), jc.IsTrue)
+	c.Assert(r.Delay(), gc.Equals, time.Duration(0))
+
+	r = b.Reserve()
+	c.Assert(r.OK(), jc.IsTrue)
+	c.Assert(r.Delay(), gc.Equals, 100*time.Millisecond)
+	r = b.Reserve()
+	c.Assert(r.Delay(), gc.Equals, 200*time.Millisecond)
+	c.Assert(b.Available(), gc.Equals, -2.0)
+
+	// Cancelling a reservation returns its tokens.
+	r.Cancel()
+	c.Assert(b.Available(), gc.Equals, -1.0)
+	r.Cancel()
+	c.Assert(b.Available(), gc.Equals, -1.0)
+
+	s.clock.Advance(100 * time.Millisecond)
+	c.Assert(b.Allow(), jc.IsFalse)
+}
+
+func (s *bucketSuite) TestReserveNotOK(c *gc.C) {
+	b := s.newBucket(10, 2)
+	r := b.ReserveN(3)
+	c.Assert(r.OK(), jc.IsFalse)
+	c.Assert(r.Delay(), gc.Equals, time.Duration(0))
+	c.Assert(b.Available(), gc.Equals, 2.0)
+
+	b.SetRate(0)
+	c.Assert(b.AllowN(2), jc.IsTrue)
+	c.Assert(b.Reserve().OK(), jc.IsFalse)
+	err := b.Wait(context.Background())
+	c.Assert(err, gc.ErrorMatches, "cannot take 1 tokens from bucket with burst 2 and rate 0")
+}
+
+func (s *bucketSuite) TestNegativeTokens(c *gc.C) {
+	b := s.newBucket(10, 2)
+	c.Assert(b.AllowN(2), jc.IsTrue)
+	c.Assert(b.AllowN(-1), jc.IsFalse)
+	r := b.ReserveN(-1)
+	c.Assert(r.OK(), jc.IsFalse)
+	c.Assert(r.Delay(), gc.Equals, time.Duration(0))
+	err := b.WaitN(context.Background(), -1)
+	c.Assert(err, gc.ErrorMatches, "cannot take -1 tokens from bucket with burst 2 and rate 10")
+	c.Assert(b.Available(), gc.Equals, 0.0)
+}
+
+func (s *bucketSuite) TestWait(c *gc.C) {
+	b := s.newBucket(1, 1)
+	c.Assert(b.Wait(context.Background()), jc.ErrorIsNil)
+
+	done := make(chan error)
+	go func() {
+		done <- b.Wait(context.Background())
+	}()
+	err := s.clock.WaitAdvance(time.Second, longWait, 1)
+	c.Assert(err, jc.ErrorIsNil)
+	select {
+	case err := <-done:
+		c.Assert(err, jc.ErrorIsNil)
+	case <-time.After(longWait):
+		c.Fatalf("timed out waiting for Wait")
+	}
+	c.Assert(b.Available(), gc.Equals, 0.0)
+}
+
+func (s *bucketSuite) TestWaitCancelled(c *gc.C) {
+	b := s.newBucket(1, 1)
+	c.Assert(b.Allow(), jc.IsTrue)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- b.Wait(ctx)
+	}()
+	select {
+	case <-s.clock.Notify():
+	case <-time.After(longWait):
+		c.Fatalf("timed out waiting for Wait to block")
+	}
+	cancel()
+	select {
+	case err := <-done:
+		c.Assert(err, gc.Equals, context.Canceled)
+	case <-time.After(longWait):
+		c.Fatalf("timed out waiting for Wait")
+	}
+	// The token reserved by the cancelled Wait was returned.
+	c.Assert(b.Available(), gc.Equals, 0.0)
+}
+
+func (s *bucketSuite) TestWaitDeadline(c *gc.C) {
+	b := s.newBucket(1, 1)
+	c.Assert(b.Allow(), jc.IsTrue)
+	ctx, cancel := clock.WithTimeout(context.Background(), s.clock, time.Millisecond)
+	defer cancel()
+	err := b.Wait(ctx)
+	c.Assert(err, gc.Equals, ratelimit.ErrWouldExceedDeadline)
+	c.Assert(b.Available(), gc.Equals, 0.0)
+}
+
+func (s *bucketSuite) TestSetRate(c *gc.C) {
+	b := s.newBucket(1, 10)
+	c.Assert(b.AllowN(10), jc.IsTrue)
+	s.clock.Advance(2 * time.Second)
+	b.SetRate(4)
+	c.Assert(b.Rate(), gc.Equals, 4.0)
+	// Tokens accumulated at the old rate are kept.
+	c.Assert(b.Available(), gc.Equals, 2.0)
+	s.clock.Advance(time.Second)
+	c.Assert(b.Available(), gc.Equals, 6.0)
+}
+
+func (s *bucketSuite) TestSetBurst(c *gc.C) {
+	b := s.newBucket(1, 10)
+	b.SetBurst(3)
+	c.Assert(b.Burst(), gc.Equals, 3)
+	c.Assert(b.Available(), gc.Equals, 3.0)
+	c.Assert(b.AllowN(4), jc.IsFalse)
+}
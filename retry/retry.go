@@ -0,0 +1,129 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+// Package retry provides a way of calling a function
+// repeatedly until it succeeds.
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/juju/utils"
+)
+
+// ErrStopped is returned by Call when the attempts were
+// stopped by the strategy's Stop channel.
+var ErrStopped = errors.New("retry stopped")
+
+// CallConfig holds the configuration for Call.
+type CallConfig struct {
+	// Strategy determines how many times and how often the
+	// function is called. Note that the zero strategy
+	// calls the function only once.
+	Strategy utils.AttemptStrategy
+
+	// IsFatalError, if non-nil, is called with each error returned
+	// by the function; if it returns true, Call returns the error
+	// immediately without retrying.
+	IsFatalError func(err error) bool
+
+	// NotifyFunc, if non-nil, is called with each error returned by
+	// the function and the number of the attempt, counting from
+	// one, that returned it.
+	NotifyFunc func(err error, attempt int)
+}
+
+// Call calls f until it succeeds, as determined by config.Strategy. If
+// f returns an error for which config.IsFatalError returns true, Call
+// returns that error. If the strategy allows no more attempts, Call
+// returns an *AttemptsExhaustedError holding the last error returned
+// by f. If ctx is done before f succeeds, Call returns ctx.Err().
+func Call(ctx context.Context, config CallConfig, f func() error) error {
+	var lastErr error
+	a := config.Strategy.StartContext(ctx)
+	for a.Next() {
+		err := f()
+		if err == nil {
+			return nil
+		}
+		if config.NotifyFunc != nil {
+			config.NotifyFunc(err, a.Count())
+		}
+		if config.IsFatalError != nil && config.IsFatalError(err) {
+			return err
+		}
+		lastErr = err
+	}
+	if a.Stopped() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return ErrStopped
+	}
+	return &AttemptsExhaustedError{
+		Attempts:  a.Count(),
+		LastError: lastErr,
+	}
+}
+
+// BackoffStrategy returns an attempt strategy that waits between
+// attempts for delays computed like those of a BackoffTimer with the
+// given config: starting at config.Min and multiplying by
+// config.Factor up to config.Max. Unlike a BackoffTimer, a zero
+// config.Max leaves the delay unlimited, and config.Jitter chooses
+// each delay at random between half and all of the computed delay
+// (EqualJitter) rather than varying it by up to 3%. The returned
+// strategy's Total, Min or MaxAttempts field should be set to allow
+// more than one attempt.
+func BackoffStrategy(config utils.BackoffTimerConfig) utils.AttemptStrategy {
+	s := utils.AttemptStrategy{
+		Delay:      config.Min,
+		MaxDelay:   config.Max,
+		Multiplier: float64(config.Factor),
+		Clock:      config.Clock,
+	}
+	if config.Jitter {
+		s.Jitter = utils.EqualJitter
+	}
+	return s
+}
+
+// AttemptsExhaustedError is returned by Call when the
+// function has failed for all the attempts allowed by
+// the strategy.
+type AttemptsExhaustedError struct {
+	// Attempts holds the number of attempts made.
+	Attempts int
+
+	// LastError holds the error returned by the last attempt.
+	LastError error
+}
+
+// Error implements error.
+func (e *AttemptsExhaustedError) Error() string {
+	return fmt.Sprintf("attempts exhausted after %d attempt(s): %v", e.Attempts, e.LastError)
+}
+
+// Unwrap returns the error returned by the last attempt.
+func (e *AttemptsExhaustedError) Unwrap() error {
+	return e.LastError
+}
+
+// IsAttemptsExhausted reports whether err was returned by Call
+// because the strategy allowed no more attempts.
+func IsAttemptsExhausted(err error) bool {
+	var exhausted *AttemptsExhaustedError
+	return errors.As(err, &exhausted)
+}
+
+// LastError returns the error returned by the last attempt if err is
+// an *AttemptsExhaustedError, and err itself otherwise.
+func LastError(err error) error {
+	var exhausted *AttemptsExhaustedError
+	if errors.As(err, &exhausted) {
+		return exhausted.LastError
+	}
+	return err
+}
@@ -0,0 +1,233 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package retry_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/utils"
+	"github.com/juju/utils/clock/testclock"
+	"github.com/juju/utils/retry"
+)
+
+type retrySuite struct {
+	testing.IsolationSuite
+}
+
+var _ = gc.Suite(&retrySuite{})
+
+// autoClock is a clock that advances itself
+// by the requested duration when After is called.
+type autoClock struct {
+	*testclock.Clock
+}
+
+func (c autoClock) After(d time.Duration) <-chan time.Time {
+	c.Advance(d)
+	ch := make(chan time.Time, 1)
+	ch <- c.Now()
+	return ch
+}
+
+func newClock() autoClock {
+	return autoClock{testclock.NewClock(time.Time{})}
+}
+
+func (*retrySuite) TestSuccess(c *gc.C) {
+	calls := 0
+	err := retry.Call(context.Background(), retry.CallConfig{
+		Strategy: utils.AttemptStrategy{
+			MaxAttempts: 5,
+			Delay:       time.Second,
+			Clock:       newClock(),
+		},
+	}, func() error {
+		calls++
+		if calls < 3 {
+			return errors.New("not yet")
+		}
+		return nil
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(calls, gc.Equals, 3)
+}
+
+func (*retrySuite) TestExhausted(c *gc.C) {
+	type failure struct {
+		err     string
+		attempt int
+	}
+	var notified []failure
+	calls := 0
+	clock := newClock()
+	err := retry.Call(context.Background(), retry.CallConfig{
+		Strategy: utils.AttemptStrategy{
+			MaxAttempts: 3,
+			Delay:       time.Second,
+			Clock:       clock,
+		},
+		NotifyFunc: func(err error, attempt int) {
+			notified = append(notified, failure{err.Error(), attempt})
+		},
+	}, func() error {
+		calls++
+		return fmt.Errorf("fail %d", calls)
+	})
+	c.Assert(err, gc.ErrorMatches, `attempts exhausted after 3 attempt\(s\): fail 3`)
+	c.Assert(retry.IsAttemptsExhausted(err), jc.IsTrue)
+	c.Assert(retry.LastError(err), gc.ErrorMatches, "fail 3")
+	c.Assert(notified, jc.DeepEquals, []failure{
+		{"fail 1", 1},
+		{"fail 2", 2},
+		{"fail 3", 3},
+	})
+	c.Assert(clock.Now(), gc.Equals, time.Time{}.Add(2*time.Second))
+}
+
+func (*retrySuite) TestFatalError(c *gc.C) {
+	fatal := errors.New("fatal")
+	calls := 0
+	err := retry.Call(context.Background(), retry.CallConfig{
+		Strategy: utils.AttemptStrategy{
+			MaxAttempts: 5,
+			Clock:       newClock(),
+		},
+		IsFatalError: func(err error) bool {
+			return err == fatal
+		},
+	}, func() error {
+		calls++
+		if calls == 2 {
+			return fatal
+		}
+		return errors.New("temporary")
+	})
+	c.Assert(err, gc.Equals, fatal)
+	c.Assert(retry.IsAttemptsExhausted(err), jc.IsFalse)
+	c.Assert(retry.LastError(err), gc.Equals, fatal)
+	c.Assert(calls, gc.Equals, 2)
+}
+
+func (*retrySuite) TestContextCancelled(c *gc.C) {
+	ctx, cancel := context.WithCancel(context.Background())
+	err := retry.Call(ctx, retry.CallConfig{
+		Strategy: utils.AttemptStrategy{
+			Total: time.Hour,
+			Delay: time.Second,
+			Clock: newClock(),
+		},
+	}, func() error {
+		cancel()
+		return errors.New("fail")
+	})
+	c.Assert(err, gc.Equals, context.Canceled)
+}
+
+func (*retrySuite) TestStopped(c *gc.C) {
+	stop := make(chan struct{})
+	err := retry.Call(context.Background(), retry.CallConfig{
+		Strategy: utils.AttemptStrategy{
+			Total: time.Hour,
+			Delay: time.Second,
+			Clock: newClock(),
+			Stop:  stop,
+		},
+	}, func() error {
+		close(stop)
+		return errors.New("fail")
+	})
+	c.Assert(err, gc.Equals, retry.ErrStopped)
+}
+
+func (*retrySuite) TestBackoffStrategy(c *gc.C) {
+	clock := newClock()
+	s := retry.BackoffStrategy(utils.BackoffTimerConfig{
+		Min:    time.Second,
+		Max:    5 * time.Second,
+		Factor: 2,
+		Clock:  clock,
+	})
+	s.MaxAttempts = 5
+	var times []time.Duration
+	err := retry.Call(context.Background(), retry.CallConfig{
+		Strategy: s,
+	}, func() error {
+		times = append(times, clock.Now().Sub(time.Time{}))
+		return errors.New("fail")
+	})
+	c.Assert(retry.IsAttemptsExhausted(err), jc.IsTrue)
+	c.Assert(times, jc.DeepEquals, []time.Duration{
+		0,
+		time.Second,
+		3 * time.Second,
+		7 * time.Second,
+		12 * time.Second,
+	})
+}
+
+// backoffGaps returns the delays between the attempts made
+// by Call with the given strategy when every attempt fails.
+func backoffGaps(c *gc.C, s utils.AttemptStrategy, clock autoClock) []time.Duration {
+	var times []time.Time
+	err := retry.Call(context.Background(), retry.CallConfig{
+		Strategy: s,
+	}, func() error {
+		times = append(times, clock.Now())
+		return errors.New("fail")
+	})
+	c.Assert(retry.IsAttemptsExhausted(err), jc.IsTrue)
+	var gaps []time.Duration
+	for i := 1; i < len(times); i++ {
+		gaps = append(gaps, times[i].Sub(times[i-1]))
+	}
+	return gaps
+}
+
+func (*retrySuite) TestBackoffStrategyNoMax(c *gc.C) {
+	clock := newClock()
+	s := retry.BackoffStrategy(utils.BackoffTimerConfig{
+		Min:    time.Second,
+		Factor: 2,
+		Clock:  clock,
+	})
+	s.MaxAttempts = 6
+	c.Assert(backoffGaps(c, s, clock), jc.DeepEquals, []time.Duration{
+		time.Second,
+		2 * time.Second,
+		4 * time.Second,
+		8 * time.Second,
+		16 * time.Second,
+	})
+}
+
+func (*retrySuite) TestBackoffStrategyJitter(c *gc.C) {
+	clock := newClock()
+	s := retry.BackoffStrategy(utils.BackoffTimerConfig{
+		Min:    time.Second,
+		Max:    5 * time.Second,
+		Factor: 2,
+		Jitter: true,
+		Clock:  clock,
+	})
+	c.Assert(s.Jitter, gc.Equals, utils.EqualJitter)
+	s.MaxAttempts = 5
+	gaps := backoffGaps(c, s, clock)
+	delays := []time.Duration{
+		time.Second,
+		2 * time.Second,
+		4 * time.Second,
+		5 * time.Second,
+	}
+	c.Assert(gaps, gc.HasLen, len(delays))
+	for i, gap := range gaps {
+		c.Check(gap >= delays[i]/2 && gap <= delays[i], jc.IsTrue, gc.Commentf("gap %d: %v", i, gap))
+	}
+}
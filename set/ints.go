@@ -22,43 +22,33 @@ func NewInts(initial ...int) Ints {
 
 // Size returns the number of elements in the set.
 func (is Ints) Size() int {
-	return len(is)
+	return Set[int](is).Size()
 }
 
 // IsEmpty is true for empty or uninitialized sets.
 func (is Ints) IsEmpty() bool {
-	return len(is) == 0
+	return Set[int](is).IsEmpty()
 }
 
 // Add puts a value into the set.
 func (is Ints) Add(value int) {
-	if is == nil {
-		panic("uninitalised set")
-	}
-	is[value] = true
+	Set[int](is).Add(value)
 }
 
 // Remove takes a value out of the set. If value wasn't in the set to start
 // with, this method silently succeeds.
 func (is Ints) Remove(value int) {
-	delete(is, value)
+	Set[int](is).Remove(value)
 }
 
 // Contains returns true if the value is in the set, and false otherwise.
 func (is Ints) Contains(value int) bool {
-	_, exists := is[value]
-	return exists
+	return Set[int](is).Contains(value)
 }
 
 // Values returns an unordered slice containing all the values in the set.
 func (is Ints) Values() []int {
-	result := make([]int, len(is))
-	i := 0
-	for key := range is {
-		result[i] = key
-		i++
-	}
-	return result
+	return Set[int](is).Values()
 }
 
 // SortedValues returns an ordered slice containing all the values in the set.
@@ -71,42 +61,17 @@ func (is Ints) SortedValues() []int {
 // Union returns a new Ints representing a union of the elments in the
 // method target and the parameter.
 func (is Ints) Union(other Ints) Ints {
-	result := make(Ints)
-	// Use the internal map rather than going through the friendlier functions
-	// to avoid extra allocation of slices.
-	for value := range is {
-		result[value] = true
-	}
-	for value := range other {
-		result[value] = true
-	}
-	return result
+	return Ints(Set[int](is).Union(Set[int](other)))
 }
 
 // Intersection returns a new Ints representing a intersection of the elments in the
 // method target and the parameter.
 func (is Ints) Intersection(other Ints) Ints {
-	result := make(Ints)
-	// Use the internal map rather than going through the friendlier functions
-	// to avoid extra allocation of slices.
-	for value := range is {
-		if other.Contains(value) {
-			result[value] = true
-		}
-	}
-	return result
+	return Ints(Set[int](is).Intersection(Set[int](other)))
 }
 
 // Difference returns a new Ints representing all the values in the
 // target that are not in the parameter.
 func (is Ints) Difference(other Ints) Ints {
-	result := make(Ints)
-	// Use the internal map rather than going through the friendlier functions
-	// to avoid extra allocation of slices.
-	for value := range is {
-		if !other.Contains(value) {
-			result[value] = true
-		}
-	}
-	return result
+	return Ints(Set[int](is).Difference(Set[int](other)))
 }
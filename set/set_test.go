@@ -0,0 +1,103 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package set_test
+
+import (
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/utils/set"
+)
+
+type setSuite struct {
+	testing.IsolationSuite
+}
+
+var _ = gc.Suite(setSuite{})
+
+type point struct {
+	x, y int
+}
+
+func lessPoint(a, b point) bool {
+	if a.x != b.x {
+		return a.x < b.x
+	}
+	return a.y < b.y
+}
+
+// Helper methods for the tests.
+func AssertPointValues(c *gc.C, s set.Set[point], expected ...point) {
+	// Expect an empty slice, not a nil slice for values.
+	if expected == nil {
+		expected = []point{}
+	}
+	c.Assert(s.Values(), jc.SameContents, expected)
+	c.Assert(s.Size(), gc.Equals, len(expected))
+}
+
+func (setSuite) TestEmpty(c *gc.C) {
+	s := set.New[point]()
+	AssertPointValues(c, s)
+	c.Assert(s.IsEmpty(), jc.IsTrue)
+}
+
+func (setSuite) TestInitialValues(c *gc.C) {
+	s := set.New(point{1, 2}, point{3, 4}, point{1, 2})
+	AssertPointValues(c, s, point{1, 2}, point{3, 4})
+	c.Assert(s.IsEmpty(), jc.IsFalse)
+}
+
+func (setSuite) TestAddRemoveContains(c *gc.C) {
+	s := set.New[point]()
+	s.Add(point{1, 2})
+	c.Assert(s.Contains(point{1, 2}), jc.IsTrue)
+	c.Assert(s.Contains(point{2, 1}), jc.IsFalse)
+
+	s.Remove(point{1, 2})
+	s.Remove(point{2, 1})
+	AssertPointValues(c, s)
+}
+
+func (setSuite) TestSortedValuesFunc(c *gc.C) {
+	s := set.New(point{2, 1}, point{1, 2}, point{1, 1})
+	c.Assert(s.SortedValuesFunc(lessPoint), jc.DeepEquals, []point{{1, 1}, {1, 2}, {2, 1}})
+}
+
+func (setSuite) TestUnion(c *gc.C) {
+	s1 := set.New(point{1, 1}, point{2, 2})
+	s2 := set.New(point{1, 1}, point{3, 3})
+	AssertPointValues(c, s1.Union(s2), point{1, 1}, point{2, 2}, point{3, 3})
+	AssertPointValues(c, s2.Union(s1), point{1, 1}, point{2, 2}, point{3, 3})
+}
+
+func (setSuite) TestIntersection(c *gc.C) {
+	s1 := set.New(point{1, 1}, point{2, 2})
+	s2 := set.New(point{1, 1}, point{3, 3})
+	AssertPointValues(c, s1.Intersection(s2), point{1, 1})
+	AssertPointValues(c, s2.Intersection(s1), point{1, 1})
+}
+
+func (setSuite) TestDifference(c *gc.C) {
+	s1 := set.New(point{1, 1}, point{2, 2})
+	s2 := set.New(point{1, 1}, point{3, 3})
+	AssertPointValues(c, s1.Difference(s2), point{2, 2})
+	AssertPointValues(c, s2.Difference(s1), point{3, 3})
+}
+
+func (setSuite) TestConversion(c *gc.C) {
+	// Strings and Set[string] have the same representation.
+	s := set.Set[string](set.NewStrings("a", "b"))
+	c.Assert(s.Contains("a"), jc.IsTrue)
+	c.Assert(set.Strings(s).SortedValues(), jc.DeepEquals, []string{"a", "b"})
+}
+
+func (setSuite) TestUninitializedPanics(c *gc.C) {
+	f := func() {
+		var s set.Set[point]
+		s.Add(point{})
+	}
+	c.Assert(f, gc.PanicMatches, "uninitalised set")
+}
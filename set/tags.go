@@ -40,43 +40,33 @@ func NewTagsFromStrings(initial ...string) (Tags, error) {
 
 // Size returns the number of elements in the set.
 func (t Tags) Size() int {
-	return len(t)
+	return Set[names.Tag](t).Size()
 }
 
 // IsEmpty is true for empty or uninitialized sets.
 func (t Tags) IsEmpty() bool {
-	return len(t) == 0
+	return Set[names.Tag](t).IsEmpty()
 }
 
 // Add puts a value into the set.
 func (t Tags) Add(value names.Tag) {
-	if t == nil {
-		panic("uninitalised set")
-	}
-	t[value] = true
+	Set[names.Tag](t).Add(value)
 }
 
 // Remove takes a value out of the set.  If value wasn't in the set to start
 // with, this method silently succeeds.
 func (t Tags) Remove(value names.Tag) {
-	delete(t, value)
+	Set[names.Tag](t).Remove(value)
 }
 
 // Contains returns true if the value is in the set, and false otherwise.
 func (t Tags) Contains(value names.Tag) bool {
-	_, exists := t[value]
-	return exists
+	return Set[names.Tag](t).Contains(value)
 }
 
 // Values returns an unordered slice containing all the values in the set.
 func (t Tags) Values() []names.Tag {
-	result := make([]names.Tag, len(t))
-	i := 0
-	for key := range t {
-		result[i] = key
-		i++
-	}
-	return result
+	return Set[names.Tag](t).Values()
 }
 
 // stringValues returns a list of strings that represent a names.Tag
@@ -109,42 +99,17 @@ func (t Tags) SortedValues() []names.Tag {
 // Union returns a new Tags representing a union of the elments in the
 // method target and the parameter.
 func (t Tags) Union(other Tags) Tags {
-	result := make(Tags)
-	// Use the internal map rather than going through the friendlier functions
-	// to avoid extra allocation of slices.
-	for value := range t {
-		result[value] = true
-	}
-	for value := range other {
-		result[value] = true
-	}
-	return result
+	return Tags(Set[names.Tag](t).Union(Set[names.Tag](other)))
 }
 
 // Intersection returns a new Tags representing a intersection of the elments in the
 // method target and the parameter.
 func (t Tags) Intersection(other Tags) Tags {
-	result := make(Tags)
-	// Use the internal map rather than going through the friendlier functions
-	// to avoid extra allocation of slices.
-	for value := range t {
-		if other.Contains(value) {
-			result[value] = true
-		}
-	}
-	return result
+	return Tags(Set[names.Tag](t).Intersection(Set[names.Tag](other)))
 }
 
 // Difference returns a new Tags representing all the values in the
 // target that are not in the parameter.
 func (t Tags) Difference(other Tags) Tags {
-	result := make(Tags)
-	// Use the internal map rather than going through the friendlier functions
-	// to avoid extra allocation of slices.
-	for value := range t {
-		if !other.Contains(value) {
-			result[value] = true
-		}
-	}
-	return result
+	return Tags(Set[names.Tag](t).Difference(Set[names.Tag](other)))
 }
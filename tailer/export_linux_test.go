@@ -0,0 +1,27 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+//go:build linux
+// +build linux
+
+package tailer
+
+import (
+	"sort"
+)
+
+var NewNotifier = newNotifier
+
+// WatchedFiles returns the paths of the files
+// watched by a notifier returned by NewNotifier.
+func WatchedFiles(n notifier) []string {
+	in := n.(*inotifyNotifier)
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	paths := make([]string, 0, len(in.fileWds))
+	for path := range in.fileWds {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
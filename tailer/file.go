@@ -0,0 +1,363 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package tailer
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/juju/utils/clock"
+)
+
+// RotationKind describes how a tailed file was replaced.
+type RotationKind int
+
+const (
+	// Truncated indicates that the file shrank, so tailing
+	// restarted from its beginning.
+	Truncated RotationKind = iota + 1
+
+	// Rotated indicates that the file was renamed or removed
+	// and a new file created at the same path, so tailing
+	// continued from the beginning of the new file.
+	Rotated
+)
+
+func (k RotationKind) String() string {
+	switch k {
+	case Truncated:
+		return "truncated"
+	case Rotated:
+		return "rotated"
+	}
+	return fmt.Sprintf("RotationKind(%d)", int(k))
+}
+
+// Rotation describes a truncation or rotation of a
+// file tailed by a Tailer created with NewFileTailer.
+type Rotation struct {
+	// Path holds the path of the tailed file.
+	Path string
+
+	// Kind holds the kind of rotation.
+	Kind RotationKind
+
+	// Offset holds the offset in the old file
+	// up to which lines had been read.
+	Offset int64
+}
+
+// FileConfig holds the configuration for NewFileTailer.
+type FileConfig struct {
+	// Path holds the path of the file to tail.
+	Path string
+
+	// Writer receives the tailed lines. If it is nil, the lines
+	// are delivered as Line records on the channel returned by
+	// the tailer's Lines method instead.
+	Writer io.Writer
+
+	// Filter, if non-nil, filters the tailed lines.
+	Filter TailerFilterFunc
+
+	// FromStart specifies that tailing should start at the
+	// beginning of the file. Otherwise, it starts at the
+	// given number of filtered lines before the end.
+	FromStart bool
+	Lines     uint
+
+	// Resume, if non-nil, specifies that tailing should start at
+	// the given position, as returned by Line.Next, overriding
+	// FromStart and Lines. If the file is now shorter than the
+	// position, it is assumed to have been truncated and tailing
+	// starts at the beginning.
+	Resume *Position
+
+	// Drain specifies that when the file is rotated, any
+	// complete lines written to the old file since it was
+	// last read are tailed before switching to the new file.
+	// Otherwise they are skipped. Either way, an incomplete
+	// line at the end of the content already read from the
+	// old file is delivered rather than discarded.
+	Drain bool
+
+	// OnRotate, if non-nil, is called from the tailer's
+	// goroutine whenever the file is truncated or rotated.
+	OnRotate func(Rotation)
+
+	// MaxLineLength, if non-zero, limits the length of the tailed
+	// lines, not counting the newline, so that very long lines do
+	// not use unbounded memory. Longer lines are truncated, unless
+	// SplitLongLines is set, in which case they are delivered in
+	// partial parts of at most MaxLineLength bytes. The filter sees
+	// the truncated line, or each part.
+	MaxLineLength  int
+	SplitLongLines bool
+
+	// FlushPartial, if non-zero, specifies that a line left without
+	// a trailing newline for this long is delivered as it is,
+	// marked as partial, with the rest of the line delivered when
+	// it arrives. Otherwise an incomplete line is held back until
+	// its newline is written. When writing to a Writer, each part
+	// of a line is written followed by a newline.
+	FlushPartial time.Duration
+
+	// PollInterval holds the time between checks of the file
+	// for new data. If it is zero, one second is used.
+	PollInterval time.Duration
+
+	// Clock is used to wait for the poll interval.
+	// If it is nil, clock.WallClock is used.
+	Clock clock.Clock
+
+	// Notify specifies that the tailer should be woken as soon as
+	// the file changes, using inotify on Linux, rather than waiting
+	// for the next poll. The file is still polled, so PollInterval
+	// can be made longer; if notifications are not available, the
+	// tailer falls back to polling alone.
+	Notify bool
+}
+
+// NewFileTailer starts a Tailer which reads the file at the given path
+// line by line, like NewTailer. Unlike a Tailer reading from an
+// arbitrary ReadSeeker, it detects when the file is truncated or when
+// it is rotated by renaming or removing it and creating a new file at
+// the same path, and carries on tailing from the start of the new
+// content. The file is closed when the tailer stops.
+func NewFileTailer(config FileConfig) (*Tailer, error) {
+	t, err := newFileTailer(config)
+	if err != nil {
+		return nil, err
+	}
+	if config.Notify {
+		// Fall back to polling if notifications are unavailable.
+		if n, err := newNotifier(); err == nil {
+			if err := n.Add(config.Path); err == nil {
+				t.notifier = n
+			} else {
+				n.Close()
+			}
+		}
+	}
+	t.start()
+	return t, nil
+}
+
+// newFileTailer returns a Tailer for the file specified by
+// config, without starting it.
+func newFileTailer(config FileConfig) (*Tailer, error) {
+	f, err := os.Open(config.Path)
+	if err != nil {
+		return nil, err
+	}
+	pos, err := startPosition(f, config)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if config.PollInterval == 0 {
+		config.PollInterval = polltime
+	}
+	if config.Clock == nil {
+		config.Clock = clock.WallClock
+	}
+	t := &Tailer{
+		readSeeker:     f,
+		reader:         bufio.NewReaderSize(f, bufferSize),
+		filter:         config.Filter,
+		polltime:       config.PollInterval,
+		clock:          config.Clock,
+		path:           config.Path,
+		file:           f,
+		drain:          config.Drain,
+		onRotate:       config.OnRotate,
+		maxLineLength:  config.MaxLineLength,
+		splitLongLines: config.SplitLongLines,
+		flushPartial:   config.FlushPartial,
+		offset:         pos.Offset,
+		lineNo:         pos.Line,
+	}
+	if config.Writer != nil {
+		t.writer = bufio.NewWriter(config.Writer)
+	} else {
+		t.lines = make(chan Line)
+	}
+	return t, nil
+}
+
+// startPosition positions f where tailing should start
+// according to config, and returns that position.
+func startPosition(f *os.File, config FileConfig) (Position, error) {
+	switch {
+	case config.Resume != nil:
+		info, err := f.Stat()
+		if err != nil {
+			return Position{}, err
+		}
+		if info.Size() < config.Resume.Offset {
+			return Position{}, nil
+		}
+		_, err = f.Seek(config.Resume.Offset, os.SEEK_SET)
+		return *config.Resume, err
+	case config.FromStart:
+		return Position{}, nil
+	}
+	if err := SeekLastLines(f, config.Lines, seekFilter(config)); err != nil {
+		return Position{}, err
+	}
+	offset, err := f.Seek(0, os.SEEK_CUR)
+	if err != nil {
+		return Position{}, err
+	}
+	// Count the lines skipped so that line numbers are right.
+	lines, err := countLines(f, offset)
+	if err != nil {
+		return Position{}, err
+	}
+	return Position{
+		Offset: offset,
+		Line:   lines,
+	}, nil
+}
+
+// seekFilter returns the filter to use when seeking to the last
+// lines, which sees long lines truncated as the tailer would see them.
+func seekFilter(config FileConfig) TailerFilterFunc {
+	filter, max := config.Filter, config.MaxLineLength
+	if filter == nil || max == 0 || config.SplitLongLines {
+		return filter
+	}
+	return func(line []byte) bool {
+		if len(line) > max+1 {
+			line = append(line[:max:max], delimiter)
+		}
+		return filter(line)
+	}
+}
+
+// countLines returns the number of lines in the first n bytes
+// of r, leaving it positioned at n.
+func countLines(r io.ReadSeeker, n int64) (int64, error) {
+	if _, err := r.Seek(0, os.SEEK_SET); err != nil {
+		return 0, err
+	}
+	buf := make([]byte, bufferSize)
+	count := int64(0)
+	for n > 0 {
+		chunk := buf
+		if int64(len(chunk)) > n {
+			chunk = chunk[:n]
+		}
+		if _, err := io.ReadFull(r, chunk); err != nil {
+			return 0, err
+		}
+		count += int64(bytes.Count(chunk, delimiters))
+		n -= int64(len(chunk))
+	}
+	return count, nil
+}
+
+// checkFile checks whether the tailed file has been truncated or
+// rotated since it was opened, and if so switches to reading the
+// new content. Unless draining, any lines added to a rotated file
+// since it was last read are skipped.
+func (t *Tailer) checkFile() error {
+	offset, err := t.file.Seek(0, os.SEEK_CUR)
+	if err != nil {
+		return err
+	}
+	pathInfo, err := os.Stat(t.path)
+	if os.IsNotExist(err) {
+		// The file has been moved away and not yet
+		// replaced; keep reading the old one.
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	fileInfo, err := t.file.Stat()
+	if err != nil {
+		return err
+	}
+	switch {
+	case !os.SameFile(pathInfo, fileInfo):
+		if t.drain {
+			if err := t.readLines(); err != nil {
+				return err
+			}
+			if offset, err = t.file.Seek(0, os.SEEK_CUR); err != nil {
+				return err
+			}
+		}
+		f, err := os.Open(t.path)
+		if err != nil {
+			return err
+		}
+		if err := t.flushRemainder(); err != nil {
+			f.Close()
+			return err
+		}
+		if t.retire != nil {
+			t.retire(fileInfo, t.position())
+		}
+		t.file.Close()
+		t.file = f
+		t.readSeeker = f
+		t.reader.Reset(f)
+		t.resetPosition()
+		t.rotated(Rotated, offset)
+	case fileInfo.Size() < offset:
+		if err := t.flushRemainder(); err != nil {
+			return err
+		}
+		if _, err := t.file.Seek(0, os.SEEK_SET); err != nil {
+			return err
+		}
+		t.reader.Reset(t.file)
+		t.resetPosition()
+		t.rotated(Truncated, offset)
+	}
+	return nil
+}
+
+// flushRemainder delivers any incomplete line left at the end of the
+// content read so far, which is about to be abandoned because the file
+// has been truncated or rotated. The line is marked as partial if
+// partial lines are being flushed; otherwise it is delivered as a
+// final line without a newline.
+func (t *Tailer) flushRemainder() error {
+	if len(t.partial) == 0 {
+		return nil
+	}
+	line := t.takeLine(len(t.partial))
+	line.Partial = t.flushPartial > 0
+	if !t.isValid(line.Bytes) {
+		return nil
+	}
+	return t.emit(line)
+}
+
+// position returns the position up to which
+// the lines of the file have been delivered.
+func (t *Tailer) position() Position {
+	return Position{
+		Offset: t.offset + t.delivered,
+		Line:   t.lineNo,
+	}
+}
+
+// rotated reports a rotation of the tailed file.
+func (t *Tailer) rotated(kind RotationKind, offset int64) {
+	if t.onRotate != nil {
+		t.onRotate(Rotation{
+			Path:   t.path,
+			Kind:   kind,
+			Offset: offset,
+		})
+	}
+}
@@ -0,0 +1,224 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package tailer_test
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/utils/clock/testclock"
+	"github.com/juju/utils/tailer"
+)
+
+const longWait = 10 * time.Second
+
+type fileSuite struct {
+	testing.IsolationSuite
+	path string
+
+	mu        sync.Mutex
+	rotations []tailer.Rotation
+}
+
+var _ = gc.Suite(&fileSuite{})
+
+func (s *fileSuite) SetUpTest(c *gc.C) {
+	s.IsolationSuite.SetUpTest(c)
+	s.path = filepath.Join(c.MkDir(), "test.log")
+	s.rotations = nil
+}
+
+// startFileTailer starts a file tailer on s.path with the given
+// config, returning the tailer and a channel receiving its lines.
+func (s *fileSuite) startFileTailer(c *gc.C, config tailer.FileConfig) (*tailer.Tailer, chan string) {
+	reader, writer := io.Pipe()
+	config.Path = s.path
+	config.Writer = writer
+	if config.PollInterval == 0 {
+		config.PollInterval = 2 * time.Millisecond
+	}
+	config.OnRotate = func(r tailer.Rotation) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.rotations = append(s.rotations, r)
+	}
+	t, err := tailer.NewFileTailer(config)
+	c.Assert(err, jc.ErrorIsNil)
+	s.AddCleanup(func(*gc.C) { t.Stop() })
+	return t, startReading(c, t, reader, writer)
+}
+
+func (s *fileSuite) getRotations() []tailer.Rotation {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]tailer.Rotation(nil), s.rotations...)
+}
+
+func appendFile(c *gc.C, path string, data ...string) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	c.Assert(err, jc.ErrorIsNil)
+	defer f.Close()
+	for _, line := range data {
+		_, err := f.WriteString(line)
+		c.Assert(err, jc.ErrorIsNil)
+	}
+}
+
+func (s *fileSuite) TestNewFileTailerNotFound(c *gc.C) {
+	_, err := tailer.NewFileTailer(tailer.FileConfig{
+		Path: s.path,
+	})
+	c.Assert(err, jc.Satisfies, os.IsNotExist)
+}
+
+func (s *fileSuite) TestLastLines(c *gc.C) {
+	appendFile(c, s.path, "one\n", "two\n", "three\n")
+	_, linec := s.startFileTailer(c, tailer.FileConfig{Lines: 2})
+	assertCollected(c, linec, []string{"two\n", "three\n"}, nil)
+	appendFile(c, s.path, "four\n")
+	assertCollected(c, linec, []string{"four\n"}, nil)
+}
+
+func (s *fileSuite) TestTruncated(c *gc.C) {
+	appendFile(c, s.path, "one\n", "two\n")
+	_, linec := s.startFileTailer(c, tailer.FileConfig{FromStart: true})
+	assertCollected(c, linec, []string{"one\n", "two\n"}, nil)
+
+	err := os.Truncate(s.path, 0)
+	c.Assert(err, jc.ErrorIsNil)
+	appendFile(c, s.path, "new\n")
+	assertCollected(c, linec, []string{"new\n"}, nil)
+	c.Assert(s.getRotations(), jc.DeepEquals, []tailer.Rotation{{
+		Path:   s.path,
+		Kind:   tailer.Truncated,
+		Offset: 8,
+	}})
+}
+
+func (s *fileSuite) TestRotated(c *gc.C) {
+	appendFile(c, s.path, "one\n")
+	_, linec := s.startFileTailer(c, tailer.FileConfig{FromStart: true})
+	assertCollected(c, linec, []string{"one\n"}, nil)
+
+	err := os.Rename(s.path, s.path+".1")
+	c.Assert(err, jc.ErrorIsNil)
+	// Lines written to the old file before the new
+	// one is created are still tailed.
+	appendFile(c, s.path+".1", "two\n")
+	assertCollected(c, linec, []string{"two\n"}, nil)
+
+	appendFile(c, s.path, "three\n")
+	assertCollected(c, linec, []string{"three\n"}, nil)
+	appendFile(c, s.path, "four\n")
+	assertCollected(c, linec, []string{"four\n"}, nil)
+	c.Assert(s.getRotations(), jc.DeepEquals, []tailer.Rotation{{
+		Path:   s.path,
+		Kind:   tailer.Rotated,
+		Offset: 8,
+	}})
+}
+
+// rotateBetweenPolls rotates the file while the tailer, whose
+// polls are driven by the given clock, is waiting to poll, writing
+// lines to both the old and new files. It then lets the tailer poll.
+func (s *fileSuite) rotateBetweenPolls(c *gc.C, clock *testclock.Clock) {
+	appendFile(c, s.path, "two\n")
+	err := os.Rename(s.path, s.path+".1")
+	c.Assert(err, jc.ErrorIsNil)
+	appendFile(c, s.path+".1", "three\n")
+	appendFile(c, s.path, "four\n")
+	err = clock.WaitAdvance(time.Minute, longWait, 1)
+	c.Assert(err, jc.ErrorIsNil)
+}
+
+func (s *fileSuite) TestRotatedWithDrain(c *gc.C) {
+	clock := testclock.NewClock(time.Time{})
+	appendFile(c, s.path, "one\n")
+	_, linec := s.startFileTailer(c, tailer.FileConfig{
+		FromStart:    true,
+		Drain:        true,
+		PollInterval: time.Minute,
+		Clock:        clock,
+	})
+	assertCollected(c, linec, []string{"one\n"}, nil)
+
+	// All the lines written to the old file are tailed
+	// before those written to the new one.
+	s.rotateBetweenPolls(c, clock)
+	assertCollected(c, linec, []string{"two\n", "three\n", "four\n"}, nil)
+	rotations := s.getRotations()
+	c.Assert(rotations, gc.HasLen, 1)
+	c.Assert(rotations[0].Kind, gc.Equals, tailer.Rotated)
+	c.Assert(rotations[0].Offset, gc.Equals, int64(len("one\ntwo\nthree\n")))
+}
+
+func (s *fileSuite) TestRotatedWithoutDrain(c *gc.C) {
+	clock := testclock.NewClock(time.Time{})
+	appendFile(c, s.path, "one\n")
+	_, linec := s.startFileTailer(c, tailer.FileConfig{
+		FromStart:    true,
+		PollInterval: time.Minute,
+		Clock:        clock,
+	})
+	assertCollected(c, linec, []string{"one\n"}, nil)
+
+	// The lines written to the old file since
+	// it was last read are skipped.
+	s.rotateBetweenPolls(c, clock)
+	assertCollected(c, linec, []string{"four\n"}, nil)
+	rotations := s.getRotations()
+	c.Assert(rotations, gc.HasLen, 1)
+	c.Assert(rotations[0].Offset, gc.Equals, int64(len("one\n")))
+}
+
+func (s *fileSuite) TestRotatedWithDrainFlushesPartialLine(c *gc.C) {
+	appendFile(c, s.path, "one\n")
+	_, linec := s.startFileTailer(c, tailer.FileConfig{
+		FromStart: true,
+		Drain:     true,
+	})
+	assertCollected(c, linec, []string{"one\n"}, nil)
+
+	// The incomplete line left in the old file is
+	// delivered before the lines of the new one.
+	appendFile(c, s.path, "two\n", "par")
+	err := os.Rename(s.path, s.path+".1")
+	c.Assert(err, jc.ErrorIsNil)
+	appendFile(c, s.path, "new\n")
+	assertCollected(c, linec, []string{"two\n", "par\n", "new\n"}, nil)
+}
+
+func (s *fileSuite) TestRotationKindString(c *gc.C) {
+	c.Assert(tailer.Truncated.String(), gc.Equals, "truncated")
+	c.Assert(tailer.Rotated.String(), gc.Equals, "rotated")
+	c.Assert(tailer.RotationKind(0).String(), gc.Equals, "RotationKind(0)")
+}
+
+func (s *fileSuite) TestPollClock(c *gc.C) {
+	clock := testclock.NewClock(time.Time{})
+	appendFile(c, s.path, "one\n")
+	_, linec := s.startFileTailer(c, tailer.FileConfig{
+		FromStart:    true,
+		PollInterval: time.Minute,
+		Clock:        clock,
+	})
+	assertCollected(c, linec, []string{"one\n"}, nil)
+
+	appendFile(c, s.path, "two\n")
+	select {
+	case line := <-linec:
+		c.Fatalf("unexpected line before poll: %q", line)
+	case <-time.After(50 * time.Millisecond):
+	}
+	err := clock.WaitAdvance(time.Minute, longWait, 1)
+	c.Assert(err, jc.ErrorIsNil)
+	assertCollected(c, linec, []string{"two\n"}, nil)
+}
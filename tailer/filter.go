@@ -0,0 +1,104 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package tailer
+
+import (
+	"bytes"
+	"regexp"
+
+	"github.com/juju/loggo"
+)
+
+// levelFields holds the number of leading fields of a line that
+// ParseLevel searches for a level. It allows for a timestamp split
+// into date and time, preceded by the name of the agent that
+// logged the line.
+const levelFields = 4
+
+// Include returns a filter that passes the lines matching re.
+// The trailing newline is not included in the match.
+func Include(re *regexp.Regexp) TailerFilterFunc {
+	return func(line []byte) bool {
+		return re.Match(trimDelimiter(line))
+	}
+}
+
+// Exclude returns a filter that passes the lines not matching re.
+// The trailing newline is not included in the match.
+func Exclude(re *regexp.Regexp) TailerFilterFunc {
+	return Not(Include(re))
+}
+
+// Not returns a filter that passes the lines rejected by filter.
+func Not(filter TailerFilterFunc) TailerFilterFunc {
+	return func(line []byte) bool {
+		return filter != nil && !filter(line)
+	}
+}
+
+// And returns a filter that passes the lines passed by all of
+// the given filters. A nil filter passes all lines, as it does
+// when given to a Tailer.
+func And(filters ...TailerFilterFunc) TailerFilterFunc {
+	return func(line []byte) bool {
+		for _, filter := range filters {
+			if filter != nil && !filter(line) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Or returns a filter that passes the lines passed by any of the
+// given filters. A nil filter passes all lines, as it does when
+// given to a Tailer; with no filters, no lines are passed.
+func Or(filters ...TailerFilterFunc) TailerFilterFunc {
+	return func(line []byte) bool {
+		for _, filter := range filters {
+			if filter == nil || filter(line) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// MinLevel returns a filter that passes the lines written by loggo
+// at the given level or above, as determined by ParseLevel. Lines
+// with no level are always passed; the filter sees one line at a
+// time, so the continuation lines of a multi-line message pass
+// even when the message's first line is rejected.
+func MinLevel(level loggo.Level) TailerFilterFunc {
+	return func(line []byte) bool {
+		lineLevel, ok := ParseLevel(line)
+		return !ok || lineLevel >= level
+	}
+}
+
+// ParseLevel returns the loggo level of the given line, which is
+// found by looking for an upper case level name, such as INFO or
+// WARNING, among the first few space-separated fields of the line.
+// It reports false if there is none.
+func ParseLevel(line []byte) (loggo.Level, bool) {
+	fields := bytes.Fields(trimDelimiter(line))
+	if len(fields) > levelFields {
+		fields = fields[:levelFields]
+	}
+	for _, field := range fields {
+		name := string(field)
+		if name != string(bytes.ToUpper(field)) {
+			continue
+		}
+		if level, ok := loggo.ParseLevel(name); ok && level != loggo.UNSPECIFIED {
+			return level, true
+		}
+	}
+	return loggo.UNSPECIFIED, false
+}
+
+// trimDelimiter returns the line without its trailing newline.
+func trimDelimiter(line []byte) []byte {
+	return bytes.TrimSuffix(line, delimiters)
+}
@@ -0,0 +1,120 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package tailer_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"regexp"
+	"strings"
+
+	"github.com/juju/loggo"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/utils/tailer"
+)
+
+type filterSuite struct {
+	testing.IsolationSuite
+}
+
+var _ = gc.Suite(&filterSuite{})
+
+var logLines = []string{
+	"machine-0: 2016-01-02 10:00:00 INFO juju.worker started\n",
+	"machine-0: 2016-01-02 10:00:01 DEBUG juju.worker ERROR in the message\n",
+	"machine-0: 2016-01-02 10:00:02 WARNING juju.api connection lost\n",
+	"  continuation of a message\n",
+	"2016-01-02 10:00:03 ERROR juju.api file.go:12 giving up\n",
+	"machine-1: 2016-01-02 10:00:04 CRITICAL juju.worker stopped\n",
+}
+
+// filterLines returns the lines that pass the filter.
+func filterLines(lines []string, filter tailer.TailerFilterFunc) []string {
+	passed := []string{}
+	for _, line := range lines {
+		if filter == nil || filter([]byte(line)) {
+			passed = append(passed, line)
+		}
+	}
+	return passed
+}
+
+func (*filterSuite) TestParseLevel(c *gc.C) {
+	for i, test := range []struct {
+		line  string
+		level loggo.Level
+		ok    bool
+	}{
+		{logLines[0], loggo.INFO, true},
+		{logLines[1], loggo.DEBUG, true},
+		{logLines[2], loggo.WARNING, true},
+		{logLines[3], loggo.UNSPECIFIED, false},
+		{logLines[4], loggo.ERROR, true},
+		{"10:00:00 WARN short form\n", loggo.WARNING, true},
+		{"info is not a level\n", loggo.UNSPECIFIED, false},
+		{"a b c d ERROR too far along\n", loggo.UNSPECIFIED, false},
+		{"", loggo.UNSPECIFIED, false},
+	} {
+		c.Logf("test %d: %q", i, test.line)
+		level, ok := tailer.ParseLevel([]byte(test.line))
+		c.Check(level, gc.Equals, test.level)
+		c.Check(ok, gc.Equals, test.ok)
+	}
+}
+
+func (*filterSuite) TestMinLevel(c *gc.C) {
+	passed := filterLines(logLines, tailer.MinLevel(loggo.WARNING))
+	c.Assert(passed, jc.DeepEquals, []string{logLines[2], logLines[3], logLines[4], logLines[5]})
+
+	// Continuation lines pass whatever the level of their message.
+	passed = filterLines(logLines[2:4], tailer.MinLevel(loggo.ERROR))
+	c.Assert(passed, jc.DeepEquals, []string{logLines[3]})
+}
+
+func (*filterSuite) TestIncludeExclude(c *gc.C) {
+	worker := regexp.MustCompile(`juju\.worker`)
+	passed := filterLines(logLines, tailer.Include(worker))
+	c.Assert(passed, jc.DeepEquals, []string{logLines[0], logLines[1], logLines[5]})
+	passed = filterLines(logLines, tailer.Exclude(worker))
+	c.Assert(passed, jc.DeepEquals, []string{logLines[2], logLines[3], logLines[4]})
+
+	// The newline is not matched.
+	passed = filterLines(logLines, tailer.Include(regexp.MustCompile(`(started|lost)$`)))
+	c.Assert(passed, jc.DeepEquals, []string{logLines[0], logLines[2]})
+}
+
+func (*filterSuite) TestCombinators(c *gc.C) {
+	worker := tailer.Include(regexp.MustCompile(`juju\.worker`))
+	warning := tailer.MinLevel(loggo.WARNING)
+	passed := filterLines(logLines, tailer.And(worker, warning))
+	c.Assert(passed, jc.DeepEquals, []string{logLines[5]})
+	passed = filterLines(logLines, tailer.Or(worker, warning))
+	c.Assert(passed, jc.DeepEquals, logLines)
+	passed = filterLines(logLines, tailer.Not(tailer.Or(worker, warning)))
+	c.Assert(passed, gc.HasLen, 0)
+
+	// Nil filters pass everything.
+	c.Assert(filterLines(logLines, tailer.And()), jc.DeepEquals, logLines)
+	c.Assert(filterLines(logLines, tailer.And(nil, worker)), jc.DeepEquals, filterLines(logLines, worker))
+	c.Assert(filterLines(logLines, tailer.Or()), gc.HasLen, 0)
+	c.Assert(filterLines(logLines, tailer.Or(nil, worker)), jc.DeepEquals, logLines)
+	c.Assert(filterLines(logLines, tailer.Not(nil)), gc.HasLen, 0)
+}
+
+func (*filterSuite) TestSeekLastLinesWithFilter(c *gc.C) {
+	data := strings.Join(logLines, "")
+	rs := bytes.NewReader([]byte(data))
+	filter := tailer.And(
+		tailer.Exclude(regexp.MustCompile(`continuation`)),
+		tailer.MinLevel(loggo.WARNING),
+	)
+	err := tailer.SeekLastLines(rs, 3, filter)
+	c.Assert(err, jc.ErrorIsNil)
+	rest, err := ioutil.ReadAll(rs)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(string(rest), gc.Equals, strings.Join(logLines[2:], ""))
+}
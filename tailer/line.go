@@ -0,0 +1,77 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package tailer
+
+import (
+	"time"
+)
+
+// Line holds a line read by a Tailer created by NewFileTailer
+// with no Writer, or by a MultiTailer.
+type Line struct {
+	// Bytes holds the contents of the line, including
+	// the trailing newline unless the line is partial.
+	Bytes []byte
+
+	// Path holds the path of the file the line was read from.
+	Path string
+
+	// Label holds the label of the file the line was read from,
+	// when read by a MultiTailer.
+	Label string
+
+	// Offset holds the byte offset of the
+	// start of the line in the file.
+	Offset int64
+
+	// Number holds the number of the line in the file,
+	// counting from one and including lines that were
+	// filtered out.
+	Number int64
+
+	// Time holds the time the line was read.
+	Time time.Time
+
+	// Partial reports that the line is incomplete: it is a part
+	// of a line that was split because it was too long, or a line
+	// without a trailing newline that was delivered because
+	// FileConfig.FlushPartial expired. The rest of the line
+	// follows with the same Number.
+	Partial bool
+
+	// Truncated holds the number of bytes dropped from the
+	// line because it was longer than FileConfig.MaxLineLength.
+	Truncated int64
+}
+
+// Next returns the position just after the line, from which
+// tailing can be resumed without repeating the line.
+func (l Line) Next() Position {
+	pos := Position{
+		Offset: l.Offset + int64(len(l.Bytes)) + l.Truncated,
+		Line:   l.Number,
+	}
+	if l.Partial {
+		// The line itself is not yet over.
+		pos.Line--
+	}
+	return pos
+}
+
+// Position records a position in a tailed file.
+type Position struct {
+	// Offset holds the byte offset in the file.
+	Offset int64
+
+	// Line holds the number of lines before Offset.
+	Line int64
+}
+
+// Lines returns the channel on which lines are delivered when the
+// tailer was created by NewFileTailer with no Writer, or nil
+// otherwise. Lines that are not received hold up the tailer. The
+// channel is closed when the tailer stops.
+func (t *Tailer) Lines() <-chan Line {
+	return t.lines
+}
@@ -0,0 +1,285 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package tailer_test
+
+import (
+	"bytes"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/utils/clock/testclock"
+	"github.com/juju/utils/tailer"
+)
+
+// startLineTailer starts a file tailer on s.path that
+// delivers Line records.
+func (s *fileSuite) startLineTailer(c *gc.C, config tailer.FileConfig) *tailer.Tailer {
+	config.Path = s.path
+	config.PollInterval = 2 * time.Millisecond
+	t, err := tailer.NewFileTailer(config)
+	c.Assert(err, jc.ErrorIsNil)
+	s.AddCleanup(func(*gc.C) { t.Stop() })
+	return t
+}
+
+// receiveLines receives n lines from the tailer.
+func receiveLines(c *gc.C, t *tailer.Tailer, n int) []tailer.Line {
+	var lines []tailer.Line
+	timeout := time.After(longWait)
+	for len(lines) < n {
+		select {
+		case line, ok := <-t.Lines():
+			c.Assert(ok, jc.IsTrue)
+			lines = append(lines, line)
+		case <-timeout:
+			c.Fatalf("timed out waiting for lines")
+		}
+	}
+	return lines
+}
+
+func (s *fileSuite) TestLines(c *gc.C) {
+	clock := testclock.NewClock(time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC))
+	appendFile(c, s.path, "one\n", "\n", "three\n")
+	t := s.startLineTailer(c, tailer.FileConfig{
+		FromStart: true,
+		Clock:     clock,
+		Filter: func(line []byte) bool {
+			return len(bytes.TrimSpace(line)) > 0
+		},
+	})
+	lines := receiveLines(c, t, 2)
+	c.Assert(lines, jc.DeepEquals, []tailer.Line{{
+		Bytes:  []byte("one\n"),
+		Path:   s.path,
+		Offset: 0,
+		Number: 1,
+		Time:   clock.Now(),
+	}, {
+		Bytes:  []byte("three\n"),
+		Path:   s.path,
+		Offset: 5,
+		Number: 3,
+		Time:   clock.Now(),
+	}})
+	c.Assert(lines[1].Next(), gc.Equals, tailer.Position{Offset: 11, Line: 3})
+
+	c.Assert(t.Stop(), jc.ErrorIsNil)
+	select {
+	case _, ok := <-t.Lines():
+		c.Assert(ok, jc.IsFalse)
+	case <-time.After(longWait):
+		c.Fatalf("timed out waiting for lines channel to close")
+	}
+}
+
+func (s *fileSuite) TestLinesLastLinesNumbering(c *gc.C) {
+	appendFile(c, s.path, "one\n", "two\n", "three\n")
+	t := s.startLineTailer(c, tailer.FileConfig{Lines: 1})
+	lines := receiveLines(c, t, 1)
+	c.Assert(string(lines[0].Bytes), gc.Equals, "three\n")
+	c.Assert(lines[0].Offset, gc.Equals, int64(8))
+	c.Assert(lines[0].Number, gc.Equals, int64(3))
+}
+
+func (s *fileSuite) TestLinesResume(c *gc.C) {
+	appendFile(c, s.path, "one\n", "two\n")
+	t := s.startLineTailer(c, tailer.FileConfig{FromStart: true})
+	lines := receiveLines(c, t, 1)
+	checkpoint := lines[0].Next()
+	c.Assert(t.Stop(), jc.ErrorIsNil)
+
+	appendFile(c, s.path, "three\n")
+	t = s.startLineTailer(c, tailer.FileConfig{Resume: &checkpoint})
+	lines = receiveLines(c, t, 2)
+	c.Assert(string(lines[0].Bytes), gc.Equals, "two\n")
+	c.Assert(lines[0].Number, gc.Equals, int64(2))
+	c.Assert(string(lines[1].Bytes), gc.Equals, "three\n")
+	c.Assert(lines[1].Offset, gc.Equals, int64(8))
+	c.Assert(lines[1].Number, gc.Equals, int64(3))
+}
+
+func (s *fileSuite) TestLinesResumeTruncated(c *gc.C) {
+	appendFile(c, s.path, "one\n")
+	t := s.startLineTailer(c, tailer.FileConfig{
+		Resume: &tailer.Position{Offset: 100, Line: 10},
+	})
+	lines := receiveLines(c, t, 1)
+	c.Assert(lines[0].Offset, gc.Equals, int64(0))
+	c.Assert(lines[0].Number, gc.Equals, int64(1))
+}
+
+func (s *fileSuite) TestLinesAfterTruncation(c *gc.C) {
+	appendFile(c, s.path, "one\n", "two\n")
+	t := s.startLineTailer(c, tailer.FileConfig{FromStart: true})
+	receiveLines(c, t, 2)
+	err := os.Truncate(s.path, 0)
+	c.Assert(err, jc.ErrorIsNil)
+	appendFile(c, s.path, "new\n")
+	lines := receiveLines(c, t, 1)
+	c.Assert(string(lines[0].Bytes), gc.Equals, "new\n")
+	c.Assert(lines[0].Offset, gc.Equals, int64(0))
+	c.Assert(lines[0].Number, gc.Equals, int64(1))
+}
+
+func (s *fileSuite) TestLinesNilForWriterTailer(c *gc.C) {
+	appendFile(c, s.path, "one\n")
+	t, _ := s.startFileTailer(c, tailer.FileConfig{})
+	c.Assert(t.Lines(), gc.IsNil)
+}
+
+func (s *fileSuite) TestLinesTruncateLongLines(c *gc.C) {
+	long := strings.Repeat("x", 10000)
+	appendFile(c, s.path, long+"\n", "short\n")
+	t := s.startLineTailer(c, tailer.FileConfig{
+		FromStart:     true,
+		MaxLineLength: 100,
+	})
+	lines := receiveLines(c, t, 2)
+	c.Assert(string(lines[0].Bytes), gc.Equals, long[:100]+"\n")
+	c.Assert(lines[0].Truncated, gc.Equals, int64(9900))
+	c.Assert(lines[0].Partial, jc.IsFalse)
+	c.Assert(lines[0].Next(), gc.Equals, tailer.Position{Offset: 10001, Line: 1})
+	c.Assert(string(lines[1].Bytes), gc.Equals, "short\n")
+	c.Assert(lines[1].Offset, gc.Equals, int64(10001))
+	c.Assert(lines[1].Number, gc.Equals, int64(2))
+}
+
+func (s *fileSuite) TestLinesTruncateFilterAndLastLines(c *gc.C) {
+	appendFile(c, s.path, "keep this\n", "drop\n", "keep that one\n")
+	t := s.startLineTailer(c, tailer.FileConfig{
+		Lines:         2,
+		MaxLineLength: 4,
+		// Only the truncated line is seen by the filter.
+		Filter: tailer.Include(regexp.MustCompile(`^keep$`)),
+	})
+	lines := receiveLines(c, t, 2)
+	c.Assert(string(lines[0].Bytes), gc.Equals, "keep\n")
+	c.Assert(lines[0].Number, gc.Equals, int64(1))
+	c.Assert(string(lines[1].Bytes), gc.Equals, "keep\n")
+	c.Assert(lines[1].Number, gc.Equals, int64(3))
+}
+
+func (s *fileSuite) TestLinesSplitLongLines(c *gc.C) {
+	appendFile(c, s.path, "abcdefghij\n", "klmn\n")
+	t := s.startLineTailer(c, tailer.FileConfig{
+		FromStart:      true,
+		MaxLineLength:  4,
+		SplitLongLines: true,
+	})
+	lines := receiveLines(c, t, 4)
+	for i := range lines {
+		lines[i].Time = time.Time{}
+		lines[i].Path = ""
+	}
+	c.Assert(lines, jc.DeepEquals, []tailer.Line{{
+		Bytes:   []byte("abcd"),
+		Offset:  0,
+		Number:  1,
+		Partial: true,
+	}, {
+		Bytes:   []byte("efgh"),
+		Offset:  4,
+		Number:  1,
+		Partial: true,
+	}, {
+		Bytes:  []byte("ij\n"),
+		Offset: 8,
+		Number: 1,
+	}, {
+		Bytes:  []byte("klmn\n"),
+		Offset: 11,
+		Number: 2,
+	}})
+	c.Assert(lines[1].Next(), gc.Equals, tailer.Position{Offset: 8, Line: 0})
+	c.Assert(lines[2].Next(), gc.Equals, tailer.Position{Offset: 11, Line: 1})
+}
+
+func (s *fileSuite) TestSplitLongLinesWriter(c *gc.C) {
+	appendFile(c, s.path, "abcdefghij\n")
+	_, linec := s.startFileTailer(c, tailer.FileConfig{
+		FromStart:      true,
+		MaxLineLength:  4,
+		SplitLongLines: true,
+	})
+	assertCollected(c, linec, []string{"abcd\n", "efgh\n", "ij\n"}, nil)
+}
+
+func (s *fileSuite) TestLinesFlushPartial(c *gc.C) {
+	clock := testclock.NewClock(time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC))
+	appendFile(c, s.path, "one\n", "par")
+	t := s.startLineTailer(c, tailer.FileConfig{
+		FromStart:    true,
+		Clock:        clock,
+		FlushPartial: time.Second,
+	})
+	lines := receiveLines(c, t, 1)
+	c.Assert(string(lines[0].Bytes), gc.Equals, "one\n")
+
+	// The partial line is held until the timeout expires.
+	err := clock.WaitAdvance(500*time.Millisecond, longWait, 1)
+	c.Assert(err, jc.ErrorIsNil)
+	appendFile(c, s.path, "tial")
+	err = clock.WaitAdvance(500*time.Millisecond, longWait, 1)
+	c.Assert(err, jc.ErrorIsNil)
+	lines = receiveLines(c, t, 1)
+	c.Assert(string(lines[0].Bytes), gc.Equals, "partial")
+	c.Assert(lines[0].Partial, jc.IsTrue)
+	c.Assert(lines[0].Offset, gc.Equals, int64(4))
+	c.Assert(lines[0].Number, gc.Equals, int64(2))
+	c.Assert(lines[0].Time, gc.Equals, clock.Now())
+
+	appendFile(c, s.path, " line\n")
+	err = clock.WaitAdvance(2*time.Millisecond, longWait, 1)
+	c.Assert(err, jc.ErrorIsNil)
+	lines = receiveLines(c, t, 1)
+	c.Assert(string(lines[0].Bytes), gc.Equals, " line\n")
+	c.Assert(lines[0].Partial, jc.IsFalse)
+	c.Assert(lines[0].Offset, gc.Equals, int64(11))
+	c.Assert(lines[0].Number, gc.Equals, int64(2))
+}
+
+func (s *fileSuite) TestLinesTruncationFlushesPartialLine(c *gc.C) {
+	appendFile(c, s.path, "one\n", "par")
+	t := s.startLineTailer(c, tailer.FileConfig{
+		FromStart:    true,
+		FlushPartial: time.Hour,
+	})
+	lines := receiveLines(c, t, 1)
+	c.Assert(string(lines[0].Bytes), gc.Equals, "one\n")
+
+	err := os.Truncate(s.path, 0)
+	c.Assert(err, jc.ErrorIsNil)
+	appendFile(c, s.path, "new\n")
+	lines = receiveLines(c, t, 2)
+	c.Assert(string(lines[0].Bytes), gc.Equals, "par")
+	c.Assert(lines[0].Partial, jc.IsTrue)
+	c.Assert(lines[0].Offset, gc.Equals, int64(4))
+	c.Assert(lines[0].Number, gc.Equals, int64(2))
+	c.Assert(string(lines[1].Bytes), gc.Equals, "new\n")
+	c.Assert(lines[1].Offset, gc.Equals, int64(0))
+	c.Assert(lines[1].Number, gc.Equals, int64(1))
+}
+
+func (s *fileSuite) TestLinesRotationFlushesFinalLine(c *gc.C) {
+	appendFile(c, s.path, "one\n", "par")
+	t := s.startLineTailer(c, tailer.FileConfig{FromStart: true})
+	lines := receiveLines(c, t, 1)
+	c.Assert(string(lines[0].Bytes), gc.Equals, "one\n")
+
+	err := os.Rename(s.path, s.path+".1")
+	c.Assert(err, jc.ErrorIsNil)
+	appendFile(c, s.path, "new\n")
+	lines = receiveLines(c, t, 2)
+	c.Assert(string(lines[0].Bytes), gc.Equals, "par")
+	c.Assert(lines[0].Partial, jc.IsFalse)
+	c.Assert(lines[0].Next(), gc.Equals, tailer.Position{Offset: 7, Line: 2})
+	c.Assert(string(lines[1].Bytes), gc.Equals, "new\n")
+	c.Assert(lines[1].Number, gc.Equals, int64(1))
+}
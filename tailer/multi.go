@@ -0,0 +1,548 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package tailer
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"launchpad.net/tomb"
+
+	"github.com/juju/utils/clock"
+)
+
+// ErrStopped is returned by MultiTailer.Add and
+// MultiTailer.Remove when the tailer has stopped.
+var ErrStopped = errors.New("tailer stopped")
+
+// MultiConfig holds the configuration for NewMultiTailer.
+type MultiConfig struct {
+	// Filter, if non-nil, filters the tailed lines.
+	Filter TailerFilterFunc
+
+	// FromStart and Lines specify where to start tailing the
+	// files that exist when they are added, as for FileConfig.
+	// Files that are created later are always tailed from
+	// the beginning.
+	FromStart bool
+	Lines     uint
+
+	// Drain, OnRotate, MaxLineLength, SplitLongLines and
+	// FlushPartial are used for each file, as for FileConfig.
+	Drain          bool
+	OnRotate       func(Rotation)
+	MaxLineLength  int
+	SplitLongLines bool
+	FlushPartial   time.Duration
+
+	// PollInterval holds the time between checks of the files
+	// for new data, and of the patterns for new matches. If it
+	// is zero, one second is used.
+	PollInterval time.Duration
+
+	// Clock is used to wait for the poll interval.
+	// If it is nil, clock.WallClock is used.
+	Clock clock.Clock
+
+	// Notify specifies that the tailer should be woken as soon as
+	// any of the files changes, as for FileConfig. New files
+	// matching glob patterns are still found by polling.
+	Notify bool
+}
+
+// MultiTailer follows a changing set of files, delivering
+// their lines, labelled with their source, on a single
+// channel. All the files are read by a single goroutine.
+type MultiTailer struct {
+	tomb     tomb.Tomb
+	config   MultiConfig
+	notifier notifier
+	lines    chan Line
+	changed  chan struct{}
+
+	// mu guards the fields below it.
+	mu sync.Mutex
+	// patterns holds the label for each pattern.
+	patterns map[string]string
+	// opened holds the files opened by Add
+	// that the loop has not yet followed.
+	opened []openedFile
+
+	// The fields below are only accessed by the loop.
+
+	// scanned holds the label for each pattern
+	// that the loop has scanned at least once.
+	scanned map[string]string
+	// followers holds a tailer for each file being followed.
+	followers map[string]*follower
+	// retired holds the files that followers have stopped
+	// reading since the patterns were last scanned.
+	retired []retiredFile
+}
+
+// follower holds a file followed by a MultiTailer.
+type follower struct {
+	*Tailer
+	// patterns holds the patterns that have matched the file.
+	patterns map[string]bool
+}
+
+// retiredFile holds a file that a follower stopped reading because
+// it was rotated or removed. If it has been renamed to a path that
+// matches a pattern, it is followed from where the follower left off,
+// rather than read again from the start.
+type retiredFile struct {
+	info os.FileInfo
+	pos  Position
+}
+
+// openedFile holds a file opened by MultiTailer.Add.
+type openedFile struct {
+	pattern string
+	tailer  *Tailer
+}
+
+// NewMultiTailer starts a MultiTailer with no files.
+func NewMultiTailer(config MultiConfig) *MultiTailer {
+	if config.PollInterval == 0 {
+		config.PollInterval = polltime
+	}
+	if config.Clock == nil {
+		config.Clock = clock.WallClock
+	}
+	m := &MultiTailer{
+		config:    config,
+		lines:     make(chan Line),
+		changed:   make(chan struct{}, 1),
+		patterns:  make(map[string]string),
+		scanned:   make(map[string]string),
+		followers: make(map[string]*follower),
+	}
+	if config.Notify {
+		// Fall back to polling if notifications are unavailable.
+		if n, err := newNotifier(); err == nil {
+			m.notifier = n
+		}
+	}
+	go func() {
+		defer m.tomb.Done()
+		m.tomb.Kill(m.loop())
+		// Close the files only once the tomb is dying,
+		// so that Add cannot open any more.
+		m.closeAll()
+		close(m.lines)
+	}()
+	return m
+}
+
+// Add starts following the files matching the given pattern, which
+// may be a plain path or a glob pattern as understood by
+// filepath.Match. The files that match when Add is called are
+// started as specified by the configuration; files that match later
+// on are read from the beginning. Each line is labelled with the
+// given label, or with the path of its file if the label is empty.
+//
+// Add opens the files itself rather than waiting for the tailer,
+// so it does not block when lines are not being received.
+func (m *MultiTailer) Add(pattern, label string) error {
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.isDying() {
+		return ErrStopped
+	}
+	if _, ok := m.patterns[pattern]; ok {
+		return nil
+	}
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		return err
+	}
+	var opened []openedFile
+	for _, path := range paths {
+		t, err := m.openFile(path, false, nil)
+		if os.IsNotExist(err) {
+			// The file went away after it was matched.
+			continue
+		}
+		if err != nil {
+			for _, f := range opened {
+				f.tailer.file.Close()
+			}
+			return err
+		}
+		opened = append(opened, openedFile{pattern, t})
+	}
+	m.patterns[pattern] = label
+	m.opened = append(m.opened, opened...)
+	m.notifyChanged()
+	return nil
+}
+
+// Remove stops following the files matching the given
+// pattern, unless they also match another pattern.
+func (m *MultiTailer) Remove(pattern string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.isDying() {
+		return ErrStopped
+	}
+	delete(m.patterns, pattern)
+	m.notifyChanged()
+	return nil
+}
+
+// isDying reports whether the tailer is stopping.
+func (m *MultiTailer) isDying() bool {
+	select {
+	case <-m.tomb.Dying():
+		return true
+	default:
+		return false
+	}
+}
+
+// notifyChanged tells the loop that the patterns have changed.
+func (m *MultiTailer) notifyChanged() {
+	select {
+	case m.changed <- struct{}{}:
+	default:
+	}
+}
+
+// Lines returns the channel on which the lines of all the files
+// are delivered. Lines that are not received hold up the tailer.
+// The channel is closed when the tailer stops.
+func (m *MultiTailer) Lines() <-chan Line {
+	return m.lines
+}
+
+// Stop tells the tailer to stop working.
+func (m *MultiTailer) Stop() error {
+	m.tomb.Kill(nil)
+	return m.tomb.Wait()
+}
+
+// Wait waits until the tailer is stopped due to command
+// or an error. In case of an error it returns the reason.
+func (m *MultiTailer) Wait() error {
+	return m.tomb.Wait()
+}
+
+// Dead returns the channel that can be used to wait until
+// the tailer is stopped.
+func (m *MultiTailer) Dead() <-chan struct{} {
+	return m.tomb.Dead()
+}
+
+// Err returns a possible error.
+func (m *MultiTailer) Err() error {
+	return m.tomb.Err()
+}
+
+func (m *MultiTailer) loop() error {
+	var changes <-chan struct{}
+	if m.notifier != nil {
+		changes = m.notifier.Changes()
+	}
+	timer := m.config.Clock.NewTimer(m.config.PollInterval)
+	defer timer.Stop()
+	for {
+		select {
+		case <-m.tomb.Dying():
+			return nil
+		case <-m.changed:
+		case <-changes:
+			// Poll now rather than waiting for the timer.
+			if !timer.Stop() {
+				select {
+				case <-timer.Chan():
+				default:
+				}
+			}
+		case <-timer.Chan():
+		}
+		if err := m.poll(); err != nil {
+			return err
+		}
+		timer.Reset(m.pollDelay())
+	}
+}
+
+// poll brings the followed files up to date with the patterns,
+// then reads any new lines from all the files.
+func (m *MultiTailer) poll() error {
+	m.mu.Lock()
+	patterns := make(map[string]string, len(m.patterns))
+	for pattern, label := range m.patterns {
+		patterns[pattern] = label
+	}
+	opened := m.opened
+	m.opened = nil
+	m.mu.Unlock()
+
+	for pattern := range m.scanned {
+		if _, ok := patterns[pattern]; !ok {
+			m.remove(pattern)
+		}
+	}
+	for pattern, label := range patterns {
+		m.scanned[pattern] = label
+	}
+	for _, f := range opened {
+		if _, ok := m.scanned[f.pattern]; !ok {
+			// The pattern has been removed already.
+			f.tailer.file.Close()
+			continue
+		}
+		if _, ok := m.followers[f.tailer.path]; !ok {
+			info, err := f.tailer.file.Stat()
+			if err == nil && m.isFollowed(info) {
+				// The file is already followed
+				// under another path.
+				f.tailer.file.Close()
+				continue
+			}
+		}
+		m.follow(f.tailer, f.pattern)
+	}
+	retired := m.retired
+	m.retired = nil
+	for pattern := range m.scanned {
+		if err := m.scanPattern(pattern, retired); err != nil {
+			return err
+		}
+	}
+	// Read the files in a predictable order.
+	paths := make([]string, 0, len(m.followers))
+	for path := range m.followers {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		f := m.followers[path]
+		removed := m.isRemoved(path)
+		if err := f.poll(); err != nil {
+			return err
+		}
+		if removed {
+			// The file has been drained and will not
+			// come back, so stop holding it open.
+			if err := f.flushRemainder(); err != nil {
+				return err
+			}
+			if info, err := f.file.Stat(); err == nil {
+				m.retire(info, f.position())
+			}
+			m.unfollow(path)
+		}
+	}
+	return nil
+}
+
+// isRemoved reports whether the followed file at the given path
+// has been removed or renamed. Files followed under a plain path
+// pattern are never treated as removed, as they are expected to
+// be replaced by a new file at the same path.
+func (m *MultiTailer) isRemoved(path string) bool {
+	if _, ok := m.scanned[path]; ok {
+		return false
+	}
+	_, err := os.Stat(path)
+	return os.IsNotExist(err)
+}
+
+// pollDelay returns the time to wait before the next poll,
+// which is sooner than the poll interval when a partial
+// line is due to be flushed.
+func (m *MultiTailer) pollDelay() time.Duration {
+	delay := m.config.PollInterval
+	for _, f := range m.followers {
+		if d := f.pollDelay(); d < delay {
+			delay = d
+		}
+	}
+	return delay
+}
+
+// remove removes a pattern, and stops following the
+// files that no other pattern has matched.
+func (m *MultiTailer) remove(pattern string) {
+	delete(m.scanned, pattern)
+	for path, f := range m.followers {
+		delete(f.patterns, pattern)
+		if len(f.patterns) == 0 {
+			m.unfollow(path)
+		}
+	}
+	if m.notifier != nil && !hasMeta(pattern) {
+		if _, ok := m.followers[pattern]; !ok {
+			m.notifier.Remove(pattern)
+		}
+	}
+}
+
+// scanPattern follows any files matching the pattern that are not
+// already being followed. They have been created since the pattern
+// was added, so they are read from the beginning, unless they are
+// files that were followed under another path and have been renamed:
+// those still held open by a follower are left to it, and those in
+// retired are read from where their follower left off.
+func (m *MultiTailer) scanPattern(pattern string, retired []retiredFile) error {
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		return err
+	}
+	if m.notifier != nil && !hasMeta(pattern) {
+		// Watch plain paths even before they exist, so that
+		// we notice them being created.
+		m.notifier.Add(pattern)
+	}
+	for _, path := range paths {
+		if f, ok := m.followers[path]; ok {
+			f.patterns[pattern] = true
+			continue
+		}
+		info, err := os.Stat(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if m.isFollowed(info) {
+			continue
+		}
+		var resume *Position
+		for _, r := range retired {
+			if os.SameFile(r.info, info) {
+				pos := r.pos
+				resume = &pos
+				break
+			}
+		}
+		t, err := m.openFile(path, true, resume)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		m.follow(t, pattern)
+	}
+	return nil
+}
+
+// isFollowed reports whether a follower holds
+// the file with the given info open.
+func (m *MultiTailer) isFollowed(info os.FileInfo) bool {
+	for _, f := range m.followers {
+		if fileInfo, err := f.file.Stat(); err == nil && os.SameFile(fileInfo, info) {
+			return true
+		}
+	}
+	return false
+}
+
+// retire records that a follower has stopped
+// reading a file at the given position.
+func (m *MultiTailer) retire(info os.FileInfo, pos Position) {
+	m.retired = append(m.retired, retiredFile{
+		info: info,
+		pos:  pos,
+	})
+}
+
+// openFile opens the file at the given path for tailing, starting
+// at resume if it is non-nil.
+func (m *MultiTailer) openFile(path string, fromStart bool, resume *Position) (*Tailer, error) {
+	t, err := newFileTailer(FileConfig{
+		Path:           path,
+		Filter:         m.config.Filter,
+		FromStart:      fromStart || m.config.FromStart,
+		Lines:          m.config.Lines,
+		Resume:         resume,
+		Drain:          m.config.Drain,
+		OnRotate:       m.config.OnRotate,
+		MaxLineLength:  m.config.MaxLineLength,
+		SplitLongLines: m.config.SplitLongLines,
+		FlushPartial:   m.config.FlushPartial,
+		PollInterval:   m.config.PollInterval,
+		Clock:          m.config.Clock,
+	})
+	if err != nil {
+		return nil, err
+	}
+	t.lines = m.lines
+	t.dying = m.tomb.Dying()
+	t.retire = m.retire
+	return t, nil
+}
+
+// follow starts following the file opened by t as matched by the
+// given pattern. If the file is already being followed, t is closed.
+func (m *MultiTailer) follow(t *Tailer, pattern string) {
+	if f, ok := m.followers[t.path]; ok {
+		f.patterns[pattern] = true
+		t.file.Close()
+		return
+	}
+	t.label = m.scanned[pattern]
+	if t.label == "" {
+		t.label = t.path
+	}
+	m.followers[t.path] = &follower{
+		Tailer:   t,
+		patterns: map[string]bool{pattern: true},
+	}
+	if m.notifier != nil {
+		m.notifier.Add(t.path)
+	}
+}
+
+// unfollow stops following the file at the given path.
+func (m *MultiTailer) unfollow(path string) {
+	m.followers[path].file.Close()
+	delete(m.followers, path)
+	if m.notifier != nil {
+		if _, ok := m.scanned[path]; !ok {
+			m.notifier.Remove(path)
+		}
+	}
+}
+
+// closeAll closes all the files and the notifier.
+// It must be called after the tomb has been killed.
+func (m *MultiTailer) closeAll() {
+	m.mu.Lock()
+	for _, f := range m.opened {
+		f.tailer.file.Close()
+	}
+	m.opened = nil
+	m.mu.Unlock()
+	for path := range m.followers {
+		m.unfollow(path)
+	}
+	if m.notifier != nil {
+		m.notifier.Close()
+	}
+}
+
+// hasMeta reports whether path contains any of the
+// magic characters recognized by filepath.Match.
+func hasMeta(path string) bool {
+	for _, c := range path {
+		switch c {
+		case '*', '?', '[', '\\':
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,225 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package tailer_test
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/utils/tailer"
+)
+
+type multiSuite struct {
+	testing.IsolationSuite
+	dir string
+}
+
+var _ = gc.Suite(&multiSuite{})
+
+func (s *multiSuite) SetUpTest(c *gc.C) {
+	s.IsolationSuite.SetUpTest(c)
+	s.dir = c.MkDir()
+}
+
+func (s *multiSuite) path(name string) string {
+	return filepath.Join(s.dir, name)
+}
+
+func (s *multiSuite) startMultiTailer(c *gc.C, config tailer.MultiConfig) *tailer.MultiTailer {
+	if config.PollInterval == 0 {
+		config.PollInterval = 2 * time.Millisecond
+	}
+	m := tailer.NewMultiTailer(config)
+	s.AddCleanup(func(*gc.C) { m.Stop() })
+	return m
+}
+
+// labelledLine holds the parts of a Line that
+// are checked by the tests.
+type labelledLine struct {
+	label string
+	text  string
+}
+
+// receiveLabelled receives n lines from the tailer.
+func receiveLabelled(c *gc.C, m *tailer.MultiTailer, n int) []labelledLine {
+	var lines []labelledLine
+	timeout := time.After(longWait)
+	for len(lines) < n {
+		select {
+		case line, ok := <-m.Lines():
+			c.Assert(ok, jc.IsTrue)
+			lines = append(lines, labelledLine{line.Label, string(line.Bytes)})
+		case <-timeout:
+			c.Fatalf("timed out waiting for lines; got %q", lines)
+		}
+	}
+	return lines
+}
+
+// assertNoLine asserts that the tailer delivers no line for a while.
+func assertNoLine(c *gc.C, m *tailer.MultiTailer) {
+	select {
+	case line := <-m.Lines():
+		c.Fatalf("unexpected line %q from %s", line.Bytes, line.Path)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func (s *multiSuite) TestAddFiles(c *gc.C) {
+	appendFile(c, s.path("a.log"), "a1\n")
+	appendFile(c, s.path("b.log"), "b1\n")
+	m := s.startMultiTailer(c, tailer.MultiConfig{FromStart: true})
+	err := m.Add(s.path("a.log"), "a")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(receiveLabelled(c, m, 1), jc.DeepEquals, []labelledLine{{"a", "a1\n"}})
+	err = m.Add(s.path("b.log"), "")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(receiveLabelled(c, m, 1), jc.DeepEquals, []labelledLine{{s.path("b.log"), "b1\n"}})
+
+	appendFile(c, s.path("b.log"), "b2\n")
+	appendFile(c, s.path("a.log"), "a2\n")
+	lines := receiveLabelled(c, m, 2)
+	c.Assert(lines, jc.SameContents, []labelledLine{{"a", "a2\n"}, {s.path("b.log"), "b2\n"}})
+}
+
+func (s *multiSuite) TestLastLines(c *gc.C) {
+	appendFile(c, s.path("a.log"), "a1\n", "a2\n")
+	m := s.startMultiTailer(c, tailer.MultiConfig{Lines: 1})
+	err := m.Add(s.path("*.log"), "logs")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(receiveLabelled(c, m, 1), jc.DeepEquals, []labelledLine{{"logs", "a2\n"}})
+
+	// Files created later are read from the start.
+	appendFile(c, s.path("b.log"), "b1\n", "b2\n")
+	c.Assert(receiveLabelled(c, m, 2), jc.DeepEquals, []labelledLine{{"logs", "b1\n"}, {"logs", "b2\n"}})
+}
+
+func (s *multiSuite) TestGlobPicksUpNewFiles(c *gc.C) {
+	m := s.startMultiTailer(c, tailer.MultiConfig{})
+	err := m.Add(s.path("unit-*.log"), "")
+	c.Assert(err, jc.ErrorIsNil)
+	assertNoLine(c, m)
+
+	appendFile(c, s.path("unit-1.log"), "one\n")
+	appendFile(c, s.path("other.log"), "other\n")
+	c.Assert(receiveLabelled(c, m, 1), jc.DeepEquals, []labelledLine{{s.path("unit-1.log"), "one\n"}})
+	appendFile(c, s.path("unit-2.log"), "two\n")
+	c.Assert(receiveLabelled(c, m, 1), jc.DeepEquals, []labelledLine{{s.path("unit-2.log"), "two\n"}})
+	assertNoLine(c, m)
+}
+
+func (s *multiSuite) TestPlainPathCreatedLater(c *gc.C) {
+	m := s.startMultiTailer(c, tailer.MultiConfig{})
+	err := m.Add(s.path("later.log"), "later")
+	c.Assert(err, jc.ErrorIsNil)
+	appendFile(c, s.path("later.log"), "hello\n")
+	c.Assert(receiveLabelled(c, m, 1), jc.DeepEquals, []labelledLine{{"later", "hello\n"}})
+}
+
+func (s *multiSuite) TestRemove(c *gc.C) {
+	appendFile(c, s.path("a.log"), "a1\n")
+	appendFile(c, s.path("b.log"), "b1\n")
+	m := s.startMultiTailer(c, tailer.MultiConfig{FromStart: true})
+	c.Assert(m.Add(s.path("a.log"), "a"), jc.ErrorIsNil)
+	c.Assert(m.Add(s.path("*.log"), "all"), jc.ErrorIsNil)
+	c.Assert(receiveLabelled(c, m, 2), jc.DeepEquals, []labelledLine{{"a", "a1\n"}, {"all", "b1\n"}})
+
+	// a.log is still matched by the glob.
+	c.Assert(m.Remove(s.path("a.log")), jc.ErrorIsNil)
+	appendFile(c, s.path("a.log"), "a2\n")
+	c.Assert(receiveLabelled(c, m, 1), jc.DeepEquals, []labelledLine{{"a", "a2\n"}})
+
+	c.Assert(m.Remove(s.path("*.log")), jc.ErrorIsNil)
+	appendFile(c, s.path("a.log"), "a3\n")
+	appendFile(c, s.path("b.log"), "b2\n")
+	assertNoLine(c, m)
+}
+
+func (s *multiSuite) TestRotation(c *gc.C) {
+	appendFile(c, s.path("a.log"), "a1\n")
+	m := s.startMultiTailer(c, tailer.MultiConfig{FromStart: true})
+	c.Assert(m.Add(s.path("a.log"), "a"), jc.ErrorIsNil)
+	c.Assert(receiveLabelled(c, m, 1), jc.DeepEquals, []labelledLine{{"a", "a1\n"}})
+	err := os.Rename(s.path("a.log"), s.path("a.log.1"))
+	c.Assert(err, jc.ErrorIsNil)
+	appendFile(c, s.path("a.log"), "a2\n")
+	c.Assert(receiveLabelled(c, m, 1), jc.DeepEquals, []labelledLine{{"a", "a2\n"}})
+}
+
+func (s *multiSuite) TestRemovedFileUnfollowed(c *gc.C) {
+	appendFile(c, s.path("a.log"), "a1\n", "par")
+	m := s.startMultiTailer(c, tailer.MultiConfig{FromStart: true})
+	c.Assert(m.Add(s.path("*.log"), "logs"), jc.ErrorIsNil)
+	c.Assert(receiveLabelled(c, m, 1), jc.DeepEquals, []labelledLine{{"logs", "a1\n"}})
+
+	// When the file is removed, its incomplete last
+	// line is delivered as it stops being followed.
+	err := os.Remove(s.path("a.log"))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(receiveLabelled(c, m, 1), jc.DeepEquals, []labelledLine{{"logs", "par"}})
+
+	// A new file at the same path is read from the start.
+	appendFile(c, s.path("a.log"), "new\n")
+	c.Assert(receiveLabelled(c, m, 1), jc.DeepEquals, []labelledLine{{"logs", "new\n"}})
+}
+
+func (s *multiSuite) TestRotationWithinGlob(c *gc.C) {
+	appendFile(c, s.path("app.log"), "one\n", "two\n")
+	m := s.startMultiTailer(c, tailer.MultiConfig{FromStart: true})
+	c.Assert(m.Add(s.path("*.log"), "logs"), jc.ErrorIsNil)
+	c.Assert(receiveLabelled(c, m, 2), jc.DeepEquals, []labelledLine{{"logs", "one\n"}, {"logs", "two\n"}})
+
+	// The renamed file still matches the pattern, but it is
+	// not read again from the start.
+	err := os.Rename(s.path("app.log"), s.path("app-1.log"))
+	c.Assert(err, jc.ErrorIsNil)
+	appendFile(c, s.path("app.log"), "three\n")
+	c.Assert(receiveLabelled(c, m, 1), jc.DeepEquals, []labelledLine{{"logs", "three\n"}})
+
+	// Lines written to the renamed file are still tailed.
+	appendFile(c, s.path("app-1.log"), "late\n")
+	c.Assert(receiveLabelled(c, m, 1), jc.DeepEquals, []labelledLine{{"logs", "late\n"}})
+	assertNoLine(c, m)
+}
+
+func (s *multiSuite) TestRotationWithinGlobBeforeCreate(c *gc.C) {
+	appendFile(c, s.path("app.log"), "one\n")
+	m := s.startMultiTailer(c, tailer.MultiConfig{FromStart: true})
+	c.Assert(m.Add(s.path("*.log"), "logs"), jc.ErrorIsNil)
+	c.Assert(receiveLabelled(c, m, 1), jc.DeepEquals, []labelledLine{{"logs", "one\n"}})
+
+	// The old follower gives up the renamed file before
+	// the new one is created.
+	err := os.Rename(s.path("app.log"), s.path("app-1.log"))
+	c.Assert(err, jc.ErrorIsNil)
+	assertNoLine(c, m)
+	appendFile(c, s.path("app-1.log"), "late\n")
+	c.Assert(receiveLabelled(c, m, 1), jc.DeepEquals, []labelledLine{{"logs", "late\n"}})
+	appendFile(c, s.path("app.log"), "two\n")
+	c.Assert(receiveLabelled(c, m, 1), jc.DeepEquals, []labelledLine{{"logs", "two\n"}})
+	assertNoLine(c, m)
+}
+
+func (s *multiSuite) TestBadPattern(c *gc.C) {
+	m := s.startMultiTailer(c, tailer.MultiConfig{})
+	err := m.Add("[", "")
+	c.Assert(err, gc.Equals, filepath.ErrBadPattern)
+}
+
+func (s *multiSuite) TestStop(c *gc.C) {
+	appendFile(c, s.path("a.log"), "a1\n")
+	m := s.startMultiTailer(c, tailer.MultiConfig{FromStart: true})
+	c.Assert(m.Add(s.path("a.log"), "a"), jc.ErrorIsNil)
+	// Stop while the tailer is blocked delivering a line.
+	c.Assert(m.Stop(), jc.ErrorIsNil)
+	c.Assert(m.Add(s.path("b.log"), "b"), gc.Equals, tailer.ErrStopped)
+	for range m.Lines() {
+	}
+}
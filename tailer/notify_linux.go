@@ -0,0 +1,226 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+//go:build linux
+// +build linux
+
+package tailer
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	// fileEvents holds the events on a tailed file
+	// that cause the tailer to be woken.
+	fileEvents = syscall.IN_MODIFY | syscall.IN_ATTRIB | syscall.IN_MOVE_SELF | syscall.IN_DELETE_SELF
+
+	// dirEvents holds the events on the directory containing
+	// a tailed file that indicate a new file may have been
+	// created at its path.
+	dirEvents = syscall.IN_CREATE | syscall.IN_MOVED_TO
+)
+
+// inotifyNotifier implements notifier using inotify. It watches the
+// tailed files, and the directories containing them so that it can
+// follow the paths to new files when the files are rotated.
+type inotifyNotifier struct {
+	fd      int
+	file    *os.File
+	changes chan struct{}
+	done    chan struct{}
+
+	// mu guards the fields below it.
+	mu sync.Mutex
+	// dirs maps the watch descriptor of each watched
+	// directory to the names of the files watched in it.
+	dirs map[int]*watchedDir
+	// dirWds maps the path of each watched directory
+	// to its watch descriptor.
+	dirWds map[string]int
+	// fileWds maps the path of each watched file to
+	// its watch descriptor, or -1 if it does not
+	// currently exist.
+	fileWds map[string]int
+}
+
+type watchedDir struct {
+	path  string
+	names map[string]bool
+}
+
+// newNotifier returns a notifier that uses inotify to
+// report changes to files.
+func newNotifier() (notifier, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+	n := &inotifyNotifier{
+		// As the descriptor is non-blocking, the os package
+		// uses the runtime poller, so Close interrupts Read.
+		// Note that calling Fd on the file would undo this.
+		fd:      fd,
+		file:    os.NewFile(uintptr(fd), "inotify"),
+		changes: make(chan struct{}, 1),
+		done:    make(chan struct{}),
+		dirs:    make(map[int]*watchedDir),
+		dirWds:  make(map[string]int),
+		fileWds: make(map[string]int),
+	}
+	go n.loop()
+	return n, nil
+}
+
+// Add implements notifier.Add.
+func (n *inotifyNotifier) Add(path string) error {
+	// Clean the path so that it matches the paths
+	// built from directory events in handleEvents.
+	path = filepath.Clean(path)
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if _, ok := n.fileWds[path]; ok {
+		return nil
+	}
+	dirPath, name := filepath.Split(path)
+	dirPath = filepath.Clean(dirPath)
+	wd, ok := n.dirWds[dirPath]
+	if !ok {
+		var err error
+		wd, err = syscall.InotifyAddWatch(n.fd, dirPath, dirEvents)
+		if err != nil {
+			return os.NewSyscallError("inotify_add_watch", err)
+		}
+		n.dirWds[dirPath] = wd
+		if n.dirs[wd] == nil {
+			n.dirs[wd] = &watchedDir{
+				path:  dirPath,
+				names: make(map[string]bool),
+			}
+		}
+	}
+	n.dirs[wd].names[name] = true
+	n.fileWds[path] = -1
+	return n.watchFile(path)
+}
+
+// Remove implements notifier.Remove.
+func (n *inotifyNotifier) Remove(path string) error {
+	path = filepath.Clean(path)
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	fileWd, ok := n.fileWds[path]
+	if !ok {
+		return nil
+	}
+	delete(n.fileWds, path)
+	if fileWd != -1 {
+		syscall.InotifyRmWatch(n.fd, uint32(fileWd))
+	}
+	dirPath, name := filepath.Split(path)
+	dirPath = filepath.Clean(dirPath)
+	wd := n.dirWds[dirPath]
+	dir := n.dirs[wd]
+	delete(dir.names, name)
+	if len(dir.names) == 0 {
+		delete(n.dirs, wd)
+		delete(n.dirWds, dirPath)
+		syscall.InotifyRmWatch(n.fd, uint32(wd))
+	}
+	return nil
+}
+
+// watchFile starts watching the file currently at the given path,
+// replacing any previous watch on the path. A missing file is not
+// an error, as it will be watched when it is created.
+// It must be called with n.mu held.
+func (n *inotifyNotifier) watchFile(path string) error {
+	wd, err := syscall.InotifyAddWatch(n.fd, path, fileEvents)
+	if err == syscall.ENOENT {
+		return nil
+	}
+	if err != nil {
+		return os.NewSyscallError("inotify_add_watch", err)
+	}
+	if old, ok := n.fileWds[path]; ok && old != -1 && old != wd {
+		// The watch may already have gone if the
+		// old file was removed, so ignore any error.
+		syscall.InotifyRmWatch(n.fd, uint32(old))
+	}
+	n.fileWds[path] = wd
+	return nil
+}
+
+func (n *inotifyNotifier) loop() {
+	defer close(n.done)
+	buf := make([]byte, 4096)
+	for {
+		nr, err := n.file.Read(buf)
+		if err != nil {
+			// The notifier has been closed, or something
+			// is badly wrong; the tailer still polls.
+			return
+		}
+		if n.handleEvents(buf[:nr]) {
+			n.notify()
+		}
+	}
+}
+
+// handleEvents handles the events read from inotify and
+// reports whether the tailer should be woken.
+func (n *inotifyNotifier) handleEvents(buf []byte) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	changed := false
+	for len(buf) >= syscall.SizeofInotifyEvent {
+		event := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[0]))
+		end := syscall.SizeofInotifyEvent + int(event.Len)
+		if end > len(buf) {
+			break
+		}
+		name := buf[syscall.SizeofInotifyEvent:end]
+		if i := bytes.IndexByte(name, 0); i >= 0 {
+			name = name[:i]
+		}
+		buf = buf[end:]
+
+		if dir, ok := n.dirs[int(event.Wd)]; ok {
+			if event.Mask&dirEvents != 0 && dir.names[string(name)] {
+				// A new file has appeared at a watched path.
+				n.watchFile(filepath.Join(dir.path, string(name)))
+				changed = true
+			}
+			continue
+		}
+		if event.Mask&fileEvents != 0 {
+			changed = true
+		}
+	}
+	return changed
+}
+
+// notify wakes the tailer, without blocking.
+func (n *inotifyNotifier) notify() {
+	select {
+	case n.changes <- struct{}{}:
+	default:
+	}
+}
+
+// Changes implements notifier.Changes.
+func (n *inotifyNotifier) Changes() <-chan struct{} {
+	return n.changes
+}
+
+// Close implements notifier.Close.
+func (n *inotifyNotifier) Close() error {
+	err := n.file.Close()
+	<-n.done
+	return err
+}
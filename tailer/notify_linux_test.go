@@ -0,0 +1,114 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package tailer_test
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/utils/clock/testclock"
+	"github.com/juju/utils/tailer"
+)
+
+// startNotifyTailer starts a file tailer that is only woken by
+// notifications, as its clock never advances.
+func (s *fileSuite) startNotifyTailer(c *gc.C) chan string {
+	_, linec := s.startFileTailer(c, tailer.FileConfig{
+		FromStart:    true,
+		PollInterval: time.Hour,
+		Clock:        testclock.NewClock(time.Time{}),
+		Notify:       true,
+	})
+	return linec
+}
+
+func (s *fileSuite) TestNotifyModify(c *gc.C) {
+	appendFile(c, s.path, "one\n")
+	linec := s.startNotifyTailer(c)
+	assertCollected(c, linec, []string{"one\n"}, nil)
+	appendFile(c, s.path, "two\n")
+	assertCollected(c, linec, []string{"two\n"}, nil)
+	appendFile(c, s.path, "three\n")
+	assertCollected(c, linec, []string{"three\n"}, nil)
+}
+
+func (s *fileSuite) TestNotifyTruncate(c *gc.C) {
+	appendFile(c, s.path, "one\n", "two\n")
+	linec := s.startNotifyTailer(c)
+	assertCollected(c, linec, []string{"one\n", "two\n"}, nil)
+	err := os.Truncate(s.path, 0)
+	c.Assert(err, jc.ErrorIsNil)
+	appendFile(c, s.path, "new\n")
+	assertCollected(c, linec, []string{"new\n"}, nil)
+}
+
+func (s *fileSuite) TestNotifyRotate(c *gc.C) {
+	appendFile(c, s.path, "one\n")
+	linec := s.startNotifyTailer(c)
+	assertCollected(c, linec, []string{"one\n"}, nil)
+
+	err := os.Rename(s.path, s.path+".1")
+	c.Assert(err, jc.ErrorIsNil)
+	appendFile(c, s.path, "two\n")
+	assertCollected(c, linec, []string{"two\n"}, nil)
+
+	// The new file is watched too.
+	appendFile(c, s.path, "three\n")
+	assertCollected(c, linec, []string{"three\n"}, nil)
+
+	// As is a file that replaces a removed one.
+	err = os.Remove(s.path)
+	c.Assert(err, jc.ErrorIsNil)
+	appendFile(c, s.path, "four\n")
+	assertCollected(c, linec, []string{"four\n"}, nil)
+}
+
+func (s *multiSuite) TestNotify(c *gc.C) {
+	m := s.startMultiTailer(c, tailer.MultiConfig{
+		PollInterval: time.Hour,
+		Clock:        testclock.NewClock(time.Time{}),
+		Notify:       true,
+	})
+	appendFile(c, s.path("a.log"), "a1\n")
+	c.Assert(m.Add(s.path("a.log"), "a"), jc.ErrorIsNil)
+	c.Assert(m.Add(s.path("b.log"), "b"), jc.ErrorIsNil)
+	appendFile(c, s.path("a.log"), "a2\n")
+	c.Assert(receiveLabelled(c, m, 1), jc.DeepEquals, []labelledLine{{"a", "a2\n"}})
+
+	// A plain path is watched before the file exists.
+	appendFile(c, s.path("b.log"), "b1\n")
+	c.Assert(receiveLabelled(c, m, 1), jc.DeepEquals, []labelledLine{{"b", "b1\n"}})
+
+	err := os.Rename(s.path("a.log"), s.path("a.log.1"))
+	c.Assert(err, jc.ErrorIsNil)
+	appendFile(c, s.path("a.log"), "a3\n")
+	c.Assert(receiveLabelled(c, m, 1), jc.DeepEquals, []labelledLine{{"a", "a3\n"}})
+}
+
+func (s *fileSuite) TestNotifierCleansPaths(c *gc.C) {
+	n, err := tailer.NewNotifier()
+	c.Assert(err, jc.ErrorIsNil)
+	defer n.Close()
+	dir, name := filepath.Split(s.path)
+	err = n.Add(dir + "/./" + name)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(tailer.WatchedFiles(n), jc.DeepEquals, []string{s.path})
+
+	// Creating the file watches it under the same path.
+	appendFile(c, s.path, "one\n")
+	select {
+	case <-n.Changes():
+	case <-time.After(longWait):
+		c.Fatalf("timed out waiting for notification")
+	}
+	c.Assert(tailer.WatchedFiles(n), jc.DeepEquals, []string{s.path})
+
+	err = n.Remove(dir + "/" + name)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(tailer.WatchedFiles(n), gc.HasLen, 0)
+}
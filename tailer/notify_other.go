@@ -0,0 +1,17 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+//go:build !linux
+// +build !linux
+
+package tailer
+
+import (
+	"errors"
+)
+
+// newNotifier returns an error as file change
+// notifications are only supported on Linux.
+func newNotifier() (notifier, error) {
+	return nil, errors.New("file change notifications not supported")
+}
@@ -11,6 +11,8 @@ import (
 	"time"
 
 	"launchpad.net/tomb"
+
+	"github.com/juju/utils/clock"
 )
 
 const (
@@ -26,6 +28,8 @@ var (
 
 // TailerFilterFunc decides if a line shall be tailed (func is nil or
 // returns true) of shall be omitted (func returns false).
+// The line passed includes its trailing newline, if any. Filters
+// can be combined with And, Or and Not.
 type TailerFilterFunc func(line []byte) bool
 
 // Tailer reads an input line by line an tails them into the passed Writer.
@@ -38,6 +42,71 @@ type Tailer struct {
 	writer      *bufio.Writer
 	filter      TailerFilterFunc
 	polltime    time.Duration
+	clock       clock.Clock
+
+	// The fields below are only set when tailing a
+	// file by path; see NewFileTailer.
+	path     string
+	file     *os.File
+	drain    bool
+	onRotate func(Rotation)
+	notifier notifier
+
+	// retire, if set, is called with the identity of a rotated
+	// file and the position up to which it was read, just before
+	// the tailer stops reading it.
+	retire func(info os.FileInfo, pos Position)
+
+	// lines is set when delivering Line records
+	// rather than writing to a writer. Sending on it
+	// is abandoned when dying is closed. The label is
+	// attached to each record.
+	lines chan Line
+	dying <-chan struct{}
+	label string
+
+	// maxLineLength, splitLongLines and flushPartial control
+	// the handling of long and partial lines; see FileConfig.
+	maxLineLength  int
+	splitLongLines bool
+	flushPartial   time.Duration
+
+	// offset holds the offset of the start of the line being
+	// read and lineNo the number of lines read, counting those
+	// that were filtered out.
+	offset int64
+	lineNo int64
+
+	// The fields below hold the line being read. partial holds
+	// the bytes of the line not yet delivered, lineLen the number
+	// of bytes of the line read so far and delivered the number
+	// of those delivered or dropped in earlier parts of the line.
+	// complete records whether the newline has been read, and
+	// partialSince when the line was found to be incomplete.
+	partial      []byte
+	lineLen      int64
+	delivered    int64
+	complete     bool
+	partialSince time.Time
+}
+
+// notifier reports changes to tailed files,
+// so that tailers need not wait for the
+// next poll to read them.
+type notifier interface {
+	// Add starts watching the file at the given path,
+	// which need not exist yet.
+	Add(path string) error
+
+	// Remove stops watching the file at the given path.
+	Remove(path string) error
+
+	// Changes returns a channel that receives a value
+	// when any of the watched files may have changed.
+	Changes() <-chan struct{}
+
+	// Close stops the notifier.
+	Close() error
 }
 
 // NewTailer starts a Tailer which reads strings from the passed
@@ -58,12 +127,22 @@ func newTailer(readSeeker io.ReadSeeker, writer io.Writer,
 		writer:     bufio.NewWriter(writer),
 		filter:     filter,
 		polltime:   polltime,
+		clock:      clock.WallClock,
 	}
+	t.start()
+	return t
+}
+
+// start starts the tailer's goroutine.
+func (t *Tailer) start() {
+	t.dying = t.tomb.Dying()
 	go func() {
 		defer t.tomb.Done()
 		t.tomb.Kill(t.loop())
+		if t.lines != nil {
+			close(t.lines)
+		}
 	}()
-	return t
 }
 
 // Stop tells the tailer to stop working.
@@ -93,34 +172,137 @@ func (t *Tailer) Err() error {
 // writer and then polls for more data to write it to the
 // writer too.
 func (t *Tailer) loop() error {
-	// Start polling.
-	// TODO(mue) 2013-12-06
-	// Handling of read-seeker/files being truncated during
-	// tailing is currently missing!
-	timer := time.NewTimer(0)
+	if t.file != nil {
+		defer func() {
+			t.file.Close()
+		}()
+	}
+	var changes <-chan struct{}
+	if t.notifier != nil {
+		defer t.notifier.Close()
+		changes = t.notifier.Changes()
+	}
+	// Start polling. Truncation and rotation can only
+	// be detected when tailing a file by path.
+	timer := t.clock.NewTimer(0)
+	defer timer.Stop()
 	for {
 		select {
 		case <-t.tomb.Dying():
 			return nil
-		case <-timer.C:
-			for {
-				line, readErr := t.readLine()
-				_, writeErr := t.writer.Write(line)
-				if writeErr != nil {
-					return writeErr
-				}
-				if readErr != nil {
-					if readErr != io.EOF {
-						return readErr
-					}
-					break
+		case <-changes:
+			// Poll now rather than waiting for the timer.
+			if !timer.Stop() {
+				select {
+				case <-timer.Chan():
+				default:
 				}
 			}
-			if writeErr := t.writer.Flush(); writeErr != nil {
-				return writeErr
-			}
-			timer.Reset(t.polltime)
+		case <-timer.Chan():
 		}
+		if err := t.poll(); err != nil {
+			return err
+		}
+		timer.Reset(t.pollDelay())
+	}
+}
+
+// poll writes any new lines to the writer.
+func (t *Tailer) poll() error {
+	if t.path != "" {
+		// Check for rotation first, so that the content
+		// of a replaced file is only read when draining.
+		if err := t.checkFile(); err != nil {
+			return err
+		}
+	}
+	if err := t.readLines(); err != nil {
+		return err
+	}
+	if t.writer == nil {
+		return nil
+	}
+	return t.writer.Flush()
+}
+
+// readLines writes all the complete lines that
+// can currently be read to the writer.
+func (t *Tailer) readLines() error {
+	for {
+		line, err := t.readLine()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if err := t.emit(line); err != nil {
+			return err
+		}
+	}
+	if line, ok := t.expiredPartial(); ok {
+		return t.emit(line)
+	}
+	return nil
+}
+
+// expiredPartial returns the partial line being read if it has
+// been waiting for its newline for longer than the flush timeout
+// and is not filtered out.
+func (t *Tailer) expiredPartial() (Line, bool) {
+	if t.flushPartial == 0 || len(t.partial) == 0 {
+		return Line{}, false
+	}
+	now := t.clock.Now()
+	if t.partialSince.IsZero() {
+		t.partialSince = now
+	}
+	if now.Sub(t.partialSince) < t.flushPartial {
+		return Line{}, false
+	}
+	line := t.takeLine(len(t.partial))
+	return line, t.isValid(line.Bytes)
+}
+
+// pollDelay returns the time to wait before the next poll,
+// which is sooner than the poll interval when a partial line
+// is due to be flushed.
+func (t *Tailer) pollDelay() time.Duration {
+	if t.flushPartial == 0 || t.partialSince.IsZero() {
+		return t.polltime
+	}
+	delay := t.partialSince.Add(t.flushPartial).Sub(t.clock.Now())
+	switch {
+	case delay < 0:
+		return 0
+	case delay < t.polltime:
+		return delay
+	}
+	return t.polltime
+}
+
+// emit writes the line just read to the writer,
+// or sends it as a Line record.
+func (t *Tailer) emit(line Line) error {
+	if t.lines == nil {
+		if _, err := t.writer.Write(line.Bytes); err != nil {
+			return err
+		}
+		if !bytes.HasSuffix(line.Bytes, delimiters) {
+			// Keep each part on a line of its own.
+			return t.writer.WriteByte(delimiter)
+		}
+		return nil
+	}
+	line.Bytes = append([]byte(nil), line.Bytes...)
+	line.Path = t.path
+	line.Label = t.label
+	line.Time = t.clock.Now()
+	select {
+	case t.lines <- line:
+		return nil
+	case <-t.dying:
+		return tomb.ErrDying
 	}
 }
 
@@ -206,36 +388,120 @@ SeekLoop:
 }
 
 // readLine reads the next valid line from the reader, even if it is
-// larger than the reader buffer.
-func (t *Tailer) readLine() ([]byte, error) {
+// larger than the reader buffer. Lines longer than the maximum line
+// length are truncated or split into parts. When no complete line
+// remains, it returns io.EOF, holding on to any partial line until
+// the rest of it can be read.
+func (t *Tailer) readLine() (Line, error) {
 	for {
-		slice, err := t.reader.ReadSlice(delimiter)
-		if err == nil {
-			if t.isValid(slice) {
-				return slice, nil
+		var line Line
+		switch {
+		case t.splitLongLines && t.maxLineLength > 0 && t.contentLen() > t.maxLineLength:
+			line = t.takeLine(t.maxLineLength)
+		case t.complete:
+			line = t.takeLine(len(t.partial))
+		default:
+			slice, err := t.reader.ReadSlice(delimiter)
+			if err != nil && err != bufio.ErrBufferFull && err != io.EOF {
+				return Line{}, err
+			}
+			t.appendPartial(slice)
+			if err == io.EOF {
+				return Line{}, err
 			}
 			continue
 		}
-		line := append([]byte(nil), slice...)
-		for err == bufio.ErrBufferFull {
-			slice, err = t.reader.ReadSlice(delimiter)
-			line = append(line, slice...)
+		if t.isValid(line.Bytes) {
+			return line, nil
 		}
-		switch err {
-		case nil:
-			if t.isValid(line) {
-				return line, nil
-			}
-		case io.EOF:
-			// EOF without delimiter, step back.
-			t.readSeeker.Seek(-int64(len(line)), os.SEEK_CUR)
-			return nil, err
-		default:
-			return nil, err
+	}
+}
+
+// appendPartial adds bytes read from the reader to the line being
+// read, dropping any beyond the maximum line length unless long
+// lines are split.
+func (t *Tailer) appendPartial(slice []byte) {
+	t.lineLen += int64(len(slice))
+	if n := len(slice); n > 0 && slice[n-1] == delimiter {
+		t.complete = true
+		slice = slice[:n-1]
+	}
+	if t.maxLineLength > 0 && !t.splitLongLines {
+		room := t.maxLineLength - len(t.partial)
+		if room < 0 {
+			room = 0
 		}
+		if len(slice) > room {
+			slice = slice[:room]
+		}
+	}
+	t.partial = append(t.partial, slice...)
+	if t.complete {
+		t.partial = append(t.partial, delimiter)
+	}
+	if len(t.partial) == 0 {
+		t.partialSince = time.Time{}
 	}
 }
 
+// contentLen returns the length of the line
+// being read, not counting any newline.
+func (t *Tailer) contentLen() int {
+	if t.complete {
+		return len(t.partial) - 1
+	}
+	return len(t.partial)
+}
+
+// takeLine removes the first n bytes of the line being read and
+// returns them as a Line. Unless they make up the rest of a complete
+// line, the Line is marked as partial and the remainder is left to
+// be delivered later. The returned bytes are only valid until the
+// next read.
+func (t *Tailer) takeLine(n int) Line {
+	line := Line{
+		Bytes:  t.partial[:n],
+		Offset: t.offset + t.delivered,
+		Number: t.lineNo + 1,
+	}
+	t.partialSince = time.Time{}
+	if n < len(t.partial) {
+		line.Partial = true
+		t.partial = t.partial[n:]
+		t.delivered += int64(n)
+		return line
+	}
+	// Account for any bytes that were dropped.
+	line.Truncated = t.lineLen - t.delivered - int64(n)
+	if !t.complete {
+		line.Partial = true
+		t.partial = t.partial[:0]
+		t.delivered = t.lineLen
+		return line
+	}
+	t.offset += t.lineLen
+	t.lineNo++
+	t.resetLine()
+	return line
+}
+
+// resetLine discards the line being read.
+func (t *Tailer) resetLine() {
+	t.partial = t.partial[:0]
+	t.lineLen = 0
+	t.delivered = 0
+	t.complete = false
+	t.partialSince = time.Time{}
+}
+
+// resetPosition records that reading has
+// restarted at the beginning of the input.
+func (t *Tailer) resetPosition() {
+	t.offset = 0
+	t.lineNo = 0
+	t.resetLine()
+}
+
 // isValid checks if the passed line is valid by checking if the
 // line has content, the filter function is nil or it returns true.
 func (t *Tailer) isValid(line []byte) bool {
@@ -20,6 +20,11 @@ type TestStdTimer struct {
 	stdStub *testing.Stub
 }
 
+func (t *TestStdTimer) Chan() <-chan time.Time {
+	t.stdStub.AddCall("Chan")
+	return nil
+}
+
 func (t *TestStdTimer) Stop() bool {
 	t.stdStub.AddCall("Stop")
 	return true
@@ -51,9 +56,11 @@ type mockClock struct {
 	properFuncCalled bool
 }
 
-// These 2 methods are not used here but are needed to satisfy the intergface
+// These methods are not used here but are needed to satisfy the intergface
 func (c *mockClock) Now() time.Time                         { return time.Now() }
 func (c *mockClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+func (c *mockClock) NewTimer(d time.Duration) clock.Timer   { return clock.WallClock.NewTimer(d) }
+func (c *mockClock) NewTicker(d time.Duration) clock.Ticker { return clock.WallClock.NewTicker(d) }
 
 func (c *mockClock) AfterFunc(d time.Duration, f func()) clock.Timer {
 	*c.afterFuncCalls++
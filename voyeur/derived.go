@@ -0,0 +1,179 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package voyeur
+
+import (
+	"reflect"
+	"sync"
+)
+
+// Source is implemented by anything that can be watched for
+// changes, such as *Value and *Derived, so that derived values
+// can themselves be derived from. A ValueOf provides a Source
+// through its Source method.
+type Source interface {
+	Watch() *Watcher
+}
+
+// Derived represents a read-only value computed from one or more
+// source values. It is updated as the sources change and is closed
+// when its sources are closed. Methods on a Derived may be called
+// concurrently.
+type Derived struct {
+	value Value
+	wg    sync.WaitGroup
+
+	// mu guards the fields below it.
+	mu       sync.Mutex
+	closed   bool
+	watchers []*Watcher
+}
+
+// Get returns the current value.
+func (d *Derived) Get() interface{} {
+	return d.value.Get()
+}
+
+// Watch returns a Watcher that can be used to watch for changes to the value.
+func (d *Derived) Watch() *Watcher {
+	return d.value.Watch()
+}
+
+// Closed reports whether the value has been closed.
+func (d *Derived) Closed() bool {
+	return d.value.Closed()
+}
+
+// Close closes the value, unblocking any outstanding watchers, and
+// stops watching the sources, which are not themselves closed. It
+// waits for the goroutines computing the value to finish. Close
+// always returns nil.
+func (d *Derived) Close() error {
+	d.stop()
+	d.wg.Wait()
+	return nil
+}
+
+// stop closes the value and the watchers of the sources.
+func (d *Derived) stop() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return
+	}
+	d.closed = true
+	for _, w := range d.watchers {
+		w.Close()
+	}
+	d.value.Close()
+}
+
+// set sets the value unless it has been closed.
+// It must be called with d.mu held.
+func (d *Derived) set(val interface{}) {
+	if !d.closed {
+		d.value.Set(val)
+	}
+}
+
+// watch starts a goroutine that calls update with d.mu held for
+// each new value of src, and closes d when src is closed.
+// It must be called with d.mu held.
+func (d *Derived) watch(src Source, update func(val interface{})) {
+	w := src.Watch()
+	d.watchers = append(d.watchers, w)
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		defer d.stop()
+		for w.Next() {
+			d.mu.Lock()
+			update(w.Value())
+			d.mu.Unlock()
+		}
+	}()
+}
+
+// Map returns a value that holds f applied to the value of src.
+func Map(src Source, f func(interface{}) interface{}) *Derived {
+	d := new(Derived)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.watch(src, func(val interface{}) {
+		d.set(f(val))
+	})
+	return d
+}
+
+// Filter returns a value that follows the value of src, ignoring
+// values for which pred returns false. Until src holds a value for
+// which pred returns true, the returned value is unset.
+func Filter(src Source, pred func(interface{}) bool) *Derived {
+	d := new(Derived)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.watch(src, func(val interface{}) {
+		if pred(val) {
+			d.set(val)
+		}
+	})
+	return d
+}
+
+// DistinctUntilChanged returns a value that follows the value of src,
+// but only changes, waking its watchers, when the new value is not
+// equal to the previous one according to equal. If equal is nil,
+// reflect.DeepEqual is used.
+func DistinctUntilChanged(src Source, equal func(a, b interface{}) bool) *Derived {
+	if equal == nil {
+		equal = reflect.DeepEqual
+	}
+	d := new(Derived)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var (
+		last    interface{}
+		hasLast bool
+	)
+	d.watch(src, func(val interface{}) {
+		if hasLast && equal(last, val) {
+			return
+		}
+		last, hasLast = val, true
+		d.set(val)
+	})
+	return d
+}
+
+// Combine returns a value that holds f applied to the values of all
+// the given sources, in order. The value is unset until every source
+// has a value, and is recomputed whenever any of them changes. It is
+// closed when any of the sources is closed, or immediately if there
+// are no sources.
+func Combine(f func(vals []interface{}) interface{}, srcs ...Source) *Derived {
+	d := new(Derived)
+	if len(srcs) == 0 {
+		d.stop()
+		return d
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	vals := make([]interface{}, len(srcs))
+	seen := make([]bool, len(srcs))
+	remaining := len(srcs)
+	for i, src := range srcs {
+		i := i
+		d.watch(src, func(val interface{}) {
+			vals[i] = val
+			if !seen[i] {
+				seen[i] = true
+				remaining--
+			}
+			if remaining == 0 {
+				d.set(f(append([]interface{}(nil), vals...)))
+			}
+		})
+	}
+	return d
+}
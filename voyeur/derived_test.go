@@ -0,0 +1,178 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package voyeur
+
+import (
+	"fmt"
+	"strings"
+
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+func (s *suite) TestMap(c *gc.C) {
+	v := NewValue("one")
+	d := Map(v, func(val interface{}) interface{} {
+		return strings.ToUpper(val.(string))
+	})
+	w := d.Watch()
+	c.Assert(w.Next(), jc.IsTrue)
+	c.Assert(w.Value(), gc.Equals, "ONE")
+
+	v.Set("two")
+	c.Assert(w.Next(), jc.IsTrue)
+	c.Assert(w.Value(), gc.Equals, "TWO")
+	c.Assert(d.Get(), gc.Equals, "TWO")
+
+	// Closing the source closes the derived value.
+	v.Close()
+	c.Assert(w.Next(), jc.IsFalse)
+	c.Assert(d.Closed(), jc.IsTrue)
+	c.Assert(d.Close(), gc.IsNil)
+}
+
+func (s *suite) TestMapOfMap(c *gc.C) {
+	v := NewValue(1)
+	double := func(val interface{}) interface{} {
+		return val.(int) * 2
+	}
+	d := Map(Map(v, double), double)
+	w := d.Watch()
+	c.Assert(w.Next(), jc.IsTrue)
+	c.Assert(w.Value(), gc.Equals, 4)
+	v.Close()
+	c.Assert(w.Next(), jc.IsFalse)
+}
+
+func (s *suite) TestValueOfSource(c *gc.C) {
+	count := NewValueOf(1)
+	name := NewValueOf("a")
+	evens := Filter(count.Source(), func(val interface{}) bool {
+		return val.(int)%2 == 0
+	})
+	d := Combine(func(vals []interface{}) interface{} {
+		return fmt.Sprintf("%s%d", vals[0].(string), vals[1].(int))
+	}, DistinctUntilChanged(name.Source(), nil), evens)
+	w := d.Watch()
+	count.Set(2)
+	c.Assert(w.Next(), jc.IsTrue)
+	c.Assert(w.Value(), gc.Equals, "a2")
+
+	count.Set(3)
+	name.Set("b")
+	c.Assert(w.Next(), jc.IsTrue)
+	c.Assert(w.Value(), gc.Equals, "b2")
+
+	// The source cannot be used to set the value.
+	_, ok := count.Source().(*Value)
+	c.Assert(ok, jc.IsFalse)
+
+	count.Close()
+	c.Assert(w.Next(), jc.IsFalse)
+	c.Assert(d.Closed(), jc.IsTrue)
+}
+
+func (s *suite) TestFilter(c *gc.C) {
+	v := NewValue(1)
+	d := Filter(v, func(val interface{}) bool {
+		return val.(int)%2 == 0
+	})
+	w := d.Watch()
+	v.Set(2)
+	c.Assert(w.Next(), jc.IsTrue)
+	c.Assert(w.Value(), gc.Equals, 2)
+
+	v.Set(3)
+	v.Set(4)
+	c.Assert(w.Next(), jc.IsTrue)
+	c.Assert(w.Value(), gc.Equals, 4)
+	v.Close()
+	c.Assert(w.Next(), jc.IsFalse)
+}
+
+func (s *suite) TestDistinctUntilChanged(c *gc.C) {
+	v := NewValue([]string{"a"})
+	d := DistinctUntilChanged(v, nil)
+	w := d.Watch()
+	c.Assert(w.Next(), jc.IsTrue)
+	c.Assert(w.Value(), jc.DeepEquals, []string{"a"})
+
+	// Setting an equal value does not wake the watcher.
+	v.Set([]string{"a"})
+	v.Set([]string{"a", "b"})
+	c.Assert(w.Next(), jc.IsTrue)
+	c.Assert(w.Value(), jc.DeepEquals, []string{"a", "b"})
+	v.Close()
+	c.Assert(w.Next(), jc.IsFalse)
+}
+
+func (s *suite) TestDistinctUntilChangedWithEqual(c *gc.C) {
+	v := NewValue("a")
+	d := DistinctUntilChanged(v, func(a, b interface{}) bool {
+		return strings.EqualFold(a.(string), b.(string))
+	})
+	w := d.Watch()
+	c.Assert(w.Next(), jc.IsTrue)
+	v.Set("A")
+	v.Set("b")
+	c.Assert(w.Next(), jc.IsTrue)
+	c.Assert(w.Value(), gc.Equals, "b")
+	c.Assert(d.Close(), gc.IsNil)
+	c.Assert(v.Closed(), jc.IsFalse)
+}
+
+func (s *suite) TestCombine(c *gc.C) {
+	host := NewValue(nil)
+	port := NewValue(nil)
+	d := Combine(func(vals []interface{}) interface{} {
+		return fmt.Sprintf("%v:%v", vals[0], vals[1])
+	}, host, port)
+	w := d.Watch()
+
+	// The combined value is unset until all the sources are set.
+	host.Set("localhost")
+	c.Assert(d.Get(), gc.IsNil)
+	port.Set(80)
+	c.Assert(w.Next(), jc.IsTrue)
+	c.Assert(w.Value(), gc.Equals, "localhost:80")
+
+	port.Set(8080)
+	c.Assert(w.Next(), jc.IsTrue)
+	c.Assert(w.Value(), gc.Equals, "localhost:8080")
+
+	// Closing any source closes the combined value.
+	port.Close()
+	c.Assert(w.Next(), jc.IsFalse)
+	c.Assert(host.Closed(), jc.IsFalse)
+	c.Assert(d.Close(), gc.IsNil)
+}
+
+func (s *suite) TestCombineNoSources(c *gc.C) {
+	d := Combine(func(vals []interface{}) interface{} {
+		return nil
+	})
+	c.Assert(d.Closed(), jc.IsTrue)
+	c.Assert(d.Close(), gc.IsNil)
+}
+
+func (s *suite) TestDerivedClose(c *gc.C) {
+	v1 := NewValue(1)
+	v2 := NewValue(2)
+	d := Combine(func(vals []interface{}) interface{} {
+		return vals[0].(int) + vals[1].(int)
+	}, v1, v2)
+	w := d.Watch()
+	c.Assert(w.Next(), jc.IsTrue)
+	c.Assert(w.Value(), gc.Equals, 3)
+
+	// Close waits for the goroutines watching the
+	// sources to exit, and leaves the sources open.
+	c.Assert(d.Close(), gc.IsNil)
+	c.Assert(w.Next(), jc.IsFalse)
+	c.Assert(v1.Closed(), jc.IsFalse)
+	c.Assert(v2.Closed(), jc.IsFalse)
+	v1.Set(10)
+	c.Assert(d.Get(), gc.Equals, 3)
+	c.Assert(d.Close(), gc.IsNil)
+}
@@ -0,0 +1,115 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package voyeur
+
+import (
+	"context"
+	"reflect"
+	"sync"
+
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+func (s *suite) TestUpdate(c *gc.C) {
+	v := NewValue(0)
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v.Update(func(old interface{}) interface{} {
+				return old.(int) + 1
+			})
+		}()
+	}
+	wg.Wait()
+	c.Assert(v.Get(), gc.Equals, 100)
+	c.Assert(v.Update(func(old interface{}) interface{} {
+		return old.(int) * 2
+	}), gc.Equals, 200)
+}
+
+func (s *suite) TestCompareAndSet(c *gc.C) {
+	v := NewValue("one")
+	w := v.Watch()
+	c.Assert(w.Next(), jc.IsTrue)
+
+	c.Assert(v.CompareAndSet("two", "three"), jc.IsFalse)
+	c.Assert(v.Get(), gc.Equals, "one")
+	assertNoChange(c, w)
+
+	c.Assert(v.CompareAndSet("one", "two"), jc.IsTrue)
+	c.Assert(v.Get(), gc.Equals, "two")
+	c.Assert(w.Next(), jc.IsTrue)
+	c.Assert(w.Value(), gc.Equals, "two")
+}
+
+func (s *suite) TestCompareAndSetUnset(c *gc.C) {
+	var v Value
+	c.Assert(v.CompareAndSet(nil, "one"), jc.IsTrue)
+	c.Assert(v.Get(), gc.Equals, "one")
+}
+
+func (s *suite) TestCompareAndSetUncomparable(c *gc.C) {
+	v := NewValue([]int{1})
+	c.Assert(func() {
+		v.CompareAndSet([]int{1}, []int{2})
+	}, gc.PanicMatches, "runtime error: comparing uncomparable type .*")
+}
+
+func (s *suite) TestValueWithEqual(c *gc.C) {
+	v := NewValueWithEqual([]int{1}, reflect.DeepEqual)
+	w := v.Watch()
+	c.Assert(w.Next(), jc.IsTrue)
+
+	// Setting an equal value does not wake the watcher.
+	v.Set([]int{1})
+	v.Update(func(old interface{}) interface{} {
+		return []int{1}
+	})
+	assertNoChange(c, w)
+
+	c.Assert(v.CompareAndSet([]int{1}, []int{2}), jc.IsTrue)
+	c.Assert(w.Next(), jc.IsTrue)
+	c.Assert(w.Value(), jc.DeepEquals, []int{2})
+	c.Assert(v.CompareAndSet([]int{1}, []int{3}), jc.IsFalse)
+}
+
+func (s *suite) TestValueOfUpdateAndCompareAndSet(c *gc.C) {
+	var v ValueOf[int]
+	// An unset value matches the zero value.
+	c.Assert(v.CompareAndSet(0, 1), jc.IsTrue)
+	c.Assert(v.CompareAndSet(0, 2), jc.IsFalse)
+	c.Assert(v.Update(func(old int) int {
+		return old + 10
+	}), gc.Equals, 11)
+	c.Assert(v.Get(), gc.Equals, 11)
+}
+
+func (s *suite) TestValueOfWithEqual(c *gc.C) {
+	type point struct{ x, y int }
+	v := NewValueOfWithEqual(point{1, 2}, func(a, b point) bool {
+		return a.x == b.x
+	})
+	w := v.Watch()
+	c.Assert(w.Next(), jc.IsTrue)
+	v.Set(point{1, 3})
+	assertNoChange(c, w)
+	c.Assert(v.Get(), gc.Equals, point{1, 2})
+	c.Assert(v.CompareAndSet(point{1, 5}, point{2, 0}), jc.IsTrue)
+	c.Assert(w.Next(), jc.IsTrue)
+	c.Assert(w.Value(), gc.Equals, point{2, 0})
+}
+
+// assertNoChange asserts that w does not see a new value.
+func assertNoChange(c *gc.C, w interface {
+	NextContext(context.Context) (bool, error)
+}) {
+	ctx, cancel := context.WithTimeout(context.Background(), shortWait)
+	defer cancel()
+	ok, err := w.NextContext(ctx)
+	c.Assert(err, gc.Equals, context.DeadlineExceeded)
+	c.Assert(ok, jc.IsFalse)
+}
@@ -6,6 +6,7 @@
 package voyeur
 
 import (
+	"context"
 	"sync"
 )
 
@@ -19,6 +20,16 @@ type Value struct {
 	mu      sync.RWMutex
 	wait    sync.Cond
 	closed  bool
+
+	// equal, if non-nil, reports whether two values are equal.
+	// Setting a value equal to the current one does not
+	// change the version or wake any watchers.
+	equal func(a, b interface{}) bool
+
+	// changed is closed, and set to nil, when the value is
+	// set or closed. It is created on demand by watchers
+	// waiting in NextContext.
+	changed chan struct{}
 }
 
 // NewValue creates a new Value holding the given initial value. If initial is
@@ -33,6 +44,17 @@ func NewValue(initial interface{}) *Value {
 	return v
 }
 
+// NewValueWithEqual is like NewValue except that setting the value to one
+// that equal reports as equal to the current value leaves the value
+// unchanged and does not wake any watchers. The equal function is also
+// used by CompareAndSet. It is called with the Value locked, so it must
+// not call any methods on the Value.
+func NewValueWithEqual(initial interface{}, equal func(a, b interface{}) bool) *Value {
+	v := NewValue(initial)
+	v.equal = equal
+	return v
+}
+
 func (v *Value) needsInit() bool {
 	return v.wait.L == nil
 }
@@ -43,14 +65,84 @@ func (v *Value) init() {
 	}
 }
 
-// Set sets the shared value to val.
+// Set sets the shared value to val. If the Value was created
+// with NewValueWithEqual and val is equal to the current value,
+// Set does nothing.
 func (v *Value) Set(val interface{}) {
 	v.mu.Lock()
 	v.init()
+	changed := v.set(val)
+	v.mu.Unlock()
+	if changed {
+		v.wait.Broadcast()
+	}
+}
+
+// Update atomically replaces the shared value with the result of
+// calling f with the current value, and returns the new value. As
+// with Set, the value is left unchanged if the new value is equal to
+// the current one. The function is called with the Value locked, so
+// it must not call any methods on the Value.
+func (v *Value) Update(f func(old interface{}) interface{}) interface{} {
+	v.mu.Lock()
+	v.init()
+	val := f(v.val)
+	changed := v.set(val)
+	v.mu.Unlock()
+	if changed {
+		v.wait.Broadcast()
+	}
+	return val
+}
+
+// CompareAndSet sets the shared value to new if the current value is
+// equal to old, and reports whether it did so. Values are compared
+// with the Value's equality function if it has one, or with the ==
+// operator otherwise, in which case CompareAndSet panics if the
+// values are not comparable.
+func (v *Value) CompareAndSet(old, new interface{}) bool {
+	return v.compareAndSet(func(val interface{}) bool {
+		return v.isEqual(val, old)
+	}, new)
+}
+
+// compareAndSet sets the shared value to new if match returns true
+// for the current value, and reports whether it did so.
+func (v *Value) compareAndSet(match func(val interface{}) bool, new interface{}) bool {
+	v.mu.Lock()
+	v.init()
+	if !match(v.val) {
+		v.mu.Unlock()
+		return false
+	}
+	changed := v.set(new)
+	v.mu.Unlock()
+	if changed {
+		v.wait.Broadcast()
+	}
+	return true
+}
+
+// set sets the shared value to val and reports whether the value was
+// changed, in which case the caller must broadcast on v.wait after
+// releasing v.mu. It must be called with v.mu held for writing.
+func (v *Value) set(val interface{}) bool {
+	if v.version > 0 && v.equal != nil && v.equal(v.val, val) {
+		return false
+	}
 	v.val = val
 	v.version++
-	v.mu.Unlock()
-	v.wait.Broadcast()
+	v.notify()
+	return true
+}
+
+// isEqual reports whether a and b are equal according
+// to the Value's equality function, or == if it has none.
+func (v *Value) isEqual(a, b interface{}) bool {
+	if v.equal != nil {
+		return v.equal(a, b)
+	}
+	return a == b
 }
 
 // Close closes the Value, unblocking any outstanding watchers.  Close always
@@ -59,11 +151,21 @@ func (v *Value) Close() error {
 	v.mu.Lock()
 	v.init()
 	v.closed = true
+	v.notify()
 	v.mu.Unlock()
 	v.wait.Broadcast()
 	return nil
 }
 
+// notify wakes any watchers waiting in NextContext.
+// It must be called with v.mu held for writing.
+func (v *Value) notify() {
+	if v.changed != nil {
+		close(v.changed)
+		v.changed = nil
+	}
+}
+
 // Closed reports whether the value has been closed.
 func (v *Value) Closed() bool {
 	v.mu.RLock()
@@ -80,7 +182,10 @@ func (v *Value) Get() interface{} {
 
 // Watch returns a Watcher that can be used to watch for changes to the value.
 func (v *Value) Watch() *Watcher {
-	return &Watcher{value: v}
+	return &Watcher{
+		value:   v,
+		closing: make(chan struct{}),
+	}
 }
 
 // Watcher represents a single watcher of a shared value.
@@ -88,7 +193,16 @@ type Watcher struct {
 	value   *Value
 	version int
 	current interface{}
+
+	// closed and closing are guarded by value.mu.
+	// closing is closed when the watcher is closed.
 	closed  bool
+	closing chan struct{}
+
+	// changesOnce guards the start of the
+	// goroutine that serves the Changes channel.
+	changesOnce sync.Once
+	changes     chan interface{}
 }
 
 // Next blocks until there is a new value to be retrieved from the value that is
@@ -130,12 +244,79 @@ func (w *Watcher) Next() bool {
 	}
 }
 
-// Close closes the Watcher without closing the underlying
-// value. It may be called concurrently with Next.
+// NextContext is like Next except that it also returns when the
+// context is done. It returns true if there is a new value, false
+// with a nil error if the value or the Watcher itself have been
+// closed, and false with ctx.Err() if the context is done first.
+func (w *Watcher) NextContext(ctx context.Context) (bool, error) {
+	val := w.value
+	for {
+		val.mu.Lock()
+		val.init()
+		if w.version != val.version {
+			w.version = val.version
+			w.current = val.val
+			val.mu.Unlock()
+			return true, nil
+		}
+		if val.closed || w.closed {
+			val.mu.Unlock()
+			return false, nil
+		}
+		if val.changed == nil {
+			val.changed = make(chan struct{})
+		}
+		changed := val.changed
+		val.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-w.closing:
+		case <-ctx.Done():
+			return false, ctx.Err()
+		}
+	}
+}
+
+// Changes returns a channel on which each new value retrieved from
+// the watched Value is sent, as if by calling Next and Value in a
+// loop. As with Next, intermediate values may be skipped if they are
+// not received promptly. The channel is closed when the value or the
+// Watcher itself is closed. Changes starts a goroutine that runs
+// until then, so the Watcher should be closed when the channel is no
+// longer needed. Next, NextContext and Value should not be called on
+// a Watcher whose Changes channel is in use.
+func (w *Watcher) Changes() <-chan interface{} {
+	w.changesOnce.Do(func() {
+		w.changes = make(chan interface{})
+		go w.sendChanges()
+	})
+	return w.changes
+}
+
+// sendChanges sends the watched values on w.changes
+// until the value or the watcher is closed.
+func (w *Watcher) sendChanges() {
+	defer close(w.changes)
+	for w.Next() {
+		select {
+		case w.changes <- w.current:
+		case <-w.closing:
+			return
+		}
+	}
+}
+
+// Close closes the Watcher without closing the underlying value. It
+// may be called concurrently with Next and NextContext, from any
+// goroutine, and may be called more than once.
 func (w *Watcher) Close() {
 	w.value.mu.Lock()
 	w.value.init()
-	w.closed = true
+	if !w.closed {
+		w.closed = true
+		close(w.closing)
+	}
 	w.value.mu.Unlock()
 	w.value.wait.Broadcast()
 }
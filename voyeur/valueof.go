@@ -0,0 +1,165 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package voyeur
+
+import (
+	"context"
+	"sync"
+)
+
+// ValueOf represents a shared value of type T that can be watched for
+// changes. It behaves exactly like Value, but avoids the need for
+// type assertions on the values retrieved from it. The zero ValueOf
+// is ok to use; any watchers will wait until a value is set.
+type ValueOf[T any] struct {
+	value Value
+}
+
+// NewValueOf creates a new ValueOf holding the given initial value.
+// Unlike NewValue, the initial value is always considered to be set,
+// even if it is the zero value of T.
+func NewValueOf[T any](initial T) *ValueOf[T] {
+	v := new(ValueOf[T])
+	v.value.Set(initial)
+	return v
+}
+
+// NewValueOfWithEqual is like NewValueOf except that setting the value
+// to one that equal reports as equal to the current value leaves the
+// value unchanged and does not wake any watchers, as for
+// NewValueWithEqual.
+func NewValueOfWithEqual[T any](initial T, equal func(a, b T) bool) *ValueOf[T] {
+	v := new(ValueOf[T])
+	v.value.equal = func(a, b interface{}) bool {
+		return equal(valueOf[T](a), valueOf[T](b))
+	}
+	v.value.Set(initial)
+	return v
+}
+
+// Set sets the shared value to val.
+func (v *ValueOf[T]) Set(val T) {
+	v.value.Set(val)
+}
+
+// Update atomically replaces the shared value with the result
+// of calling f with the current value, and returns the new
+// value, as for Value.Update.
+func (v *ValueOf[T]) Update(f func(old T) T) T {
+	return valueOf[T](v.value.Update(func(old interface{}) interface{} {
+		return f(valueOf[T](old))
+	}))
+}
+
+// CompareAndSet sets the shared value to new if the current value
+// is equal to old, and reports whether it did so, as for
+// Value.CompareAndSet.
+func (v *ValueOf[T]) CompareAndSet(old, new T) bool {
+	// Compare as T so that an unset value matches the zero value.
+	return v.value.compareAndSet(func(val interface{}) bool {
+		return v.value.isEqual(valueOf[T](val), old)
+	}, new)
+}
+
+// Close closes the value, unblocking any outstanding watchers. Close always
+// returns nil.
+func (v *ValueOf[T]) Close() error {
+	return v.value.Close()
+}
+
+// Closed reports whether the value has been closed.
+func (v *ValueOf[T]) Closed() bool {
+	return v.value.Closed()
+}
+
+// Get returns the current value, or the zero value
+// of T if no value has been set.
+func (v *ValueOf[T]) Get() T {
+	return valueOf[T](v.value.Get())
+}
+
+// Watch returns a WatcherOf that can be used to watch for changes to the value.
+func (v *ValueOf[T]) Watch() *WatcherOf[T] {
+	return &WatcherOf[T]{
+		watcher: v.value.Watch(),
+	}
+}
+
+// Source returns a Source that watches the value, so that derived
+// values can be computed from it with Map, Filter,
+// DistinctUntilChanged and Combine. The values passed to their
+// functions have type T.
+func (v *ValueOf[T]) Source() Source {
+	return valueOfSource{&v.value}
+}
+
+// valueOfSource implements Source for a ValueOf
+// without exposing its underlying Value.
+type valueOfSource struct {
+	value *Value
+}
+
+// Watch implements Source.Watch.
+func (s valueOfSource) Watch() *Watcher {
+	return s.value.Watch()
+}
+
+// WatcherOf represents a single watcher of a ValueOf.
+type WatcherOf[T any] struct {
+	watcher *Watcher
+
+	changesOnce sync.Once
+	changes     chan T
+}
+
+// Next blocks until there is a new value to be retrieved from the value
+// that is being watched, as for Watcher.Next.
+func (w *WatcherOf[T]) Next() bool {
+	return w.watcher.Next()
+}
+
+// NextContext is like Next except that it also returns
+// when the context is done, as for Watcher.NextContext.
+func (w *WatcherOf[T]) NextContext(ctx context.Context) (bool, error) {
+	return w.watcher.NextContext(ctx)
+}
+
+// Changes returns a channel on which each new value retrieved
+// from the watched value is sent, as for Watcher.Changes.
+func (w *WatcherOf[T]) Changes() <-chan T {
+	w.changesOnce.Do(func() {
+		w.changes = make(chan T)
+		go func() {
+			defer close(w.changes)
+			for val := range w.watcher.Changes() {
+				select {
+				case w.changes <- valueOf[T](val):
+				case <-w.watcher.closing:
+					return
+				}
+			}
+		}()
+	})
+	return w.changes
+}
+
+// Close closes the watcher without closing the underlying value. It
+// may be called concurrently with Next and NextContext, from any
+// goroutine, and may be called more than once.
+func (w *WatcherOf[T]) Close() {
+	w.watcher.Close()
+}
+
+// Value returns the last value that was retrieved from the watched value
+// by Next.
+func (w *WatcherOf[T]) Value() T {
+	return valueOf[T](w.watcher.Value())
+}
+
+// valueOf returns val as a T, or the zero
+// value of T if val is nil.
+func valueOf[T any](val interface{}) T {
+	t, _ := val.(T)
+	return t
+}
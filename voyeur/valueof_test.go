@@ -0,0 +1,66 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package voyeur
+
+import (
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+func (s *suite) TestValueOfGetSet(c *gc.C) {
+	v := NewValueOf(0)
+	c.Assert(v.Get(), gc.Equals, 0)
+	v.Set(12345)
+	c.Assert(v.Get(), gc.Equals, 12345)
+	c.Assert(v.Closed(), jc.IsFalse)
+	c.Assert(v.Close(), gc.IsNil)
+	c.Assert(v.Closed(), jc.IsTrue)
+}
+
+func (s *suite) TestValueOfInitialZeroValueIsSet(c *gc.C) {
+	v := NewValueOf("")
+	w := v.Watch()
+	c.Assert(w.Next(), jc.IsTrue)
+	c.Assert(w.Value(), gc.Equals, "")
+}
+
+func (s *suite) TestValueOfZeroValue(c *gc.C) {
+	var v ValueOf[error]
+	c.Assert(v.Get(), gc.IsNil)
+
+	w := v.Watch()
+	c.Assert(w.Value(), gc.IsNil)
+	go v.Close()
+	c.Assert(w.Next(), jc.IsFalse)
+}
+
+func (s *suite) TestWatcherOf(c *gc.C) {
+	vals := []string{"one", "two", "three"}
+	v := NewValueOf(vals[0])
+	ch := make(chan bool)
+	go func() {
+		for _, val := range vals[1:] {
+			<-ch
+			v.Set(val)
+		}
+		<-ch
+		v.Close()
+	}()
+	w := v.Watch()
+	var got []string
+	for w.Next() {
+		got = append(got, w.Value())
+		ch <- true
+	}
+	c.Assert(got, jc.DeepEquals, vals)
+}
+
+func (s *suite) TestWatcherOfClose(c *gc.C) {
+	v := NewValueOf(1)
+	w := v.Watch()
+	c.Assert(w.Next(), jc.IsTrue)
+	w.Close()
+	c.Assert(w.Next(), jc.IsFalse)
+	c.Assert(w.Value(), gc.Equals, 1)
+}
@@ -0,0 +1,153 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package voyeur
+
+import (
+	"context"
+	"time"
+
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+const (
+	shortWait = 10 * time.Millisecond
+	longWait  = 10 * time.Second
+)
+
+func (s *suite) TestNextContext(c *gc.C) {
+	v := NewValue(nil)
+	w := v.Watch()
+	ctx := context.Background()
+
+	go v.Set("one")
+	ok, err := w.NextContext(ctx)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(ok, jc.IsTrue)
+	c.Assert(w.Value(), gc.Equals, "one")
+
+	go v.Close()
+	ok, err = w.NextContext(ctx)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(ok, jc.IsFalse)
+}
+
+func (s *suite) TestNextContextCanceled(c *gc.C) {
+	v := NewValue("one")
+	w := v.Watch()
+	ctx, cancel := context.WithTimeout(context.Background(), shortWait)
+	defer cancel()
+
+	ok, err := w.NextContext(ctx)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(ok, jc.IsTrue)
+
+	ok, err = w.NextContext(ctx)
+	c.Assert(err, gc.Equals, context.DeadlineExceeded)
+	c.Assert(ok, jc.IsFalse)
+
+	// The watcher is still usable after the context is done.
+	v.Set("two")
+	ok, err = w.NextContext(context.Background())
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(ok, jc.IsTrue)
+	c.Assert(w.Value(), gc.Equals, "two")
+}
+
+func (s *suite) TestNextContextWatcherClosed(c *gc.C) {
+	v := NewValue(nil)
+	w := v.Watch()
+	go w.Close()
+	ok, err := w.NextContext(context.Background())
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(ok, jc.IsFalse)
+	c.Assert(v.Closed(), jc.IsFalse)
+}
+
+func (s *suite) TestChanges(c *gc.C) {
+	v := NewValue("one")
+	w := v.Watch()
+	changes := w.Changes()
+	c.Assert(w.Changes(), gc.Equals, changes)
+
+	c.Assert(receive(c, changes), gc.Equals, "one")
+	v.Set("two")
+	c.Assert(receive(c, changes), gc.Equals, "two")
+
+	v.Close()
+	assertClosed(c, changes)
+}
+
+func (s *suite) TestChangesWatcherClosed(c *gc.C) {
+	v := NewValue("one")
+	w := v.Watch()
+	changes := w.Changes()
+
+	// Closing the watcher closes the channel even
+	// when there is a value waiting to be sent.
+	w.Close()
+	w.Close()
+	for range changes {
+	}
+	c.Assert(v.Closed(), jc.IsFalse)
+}
+
+func (s *suite) TestChangesInSelect(c *gc.C) {
+	v1 := NewValue(nil)
+	v2 := NewValue(nil)
+	w1, w2 := v1.Watch(), v2.Watch()
+	defer w1.Close()
+	defer w2.Close()
+
+	v2.Set("two")
+	select {
+	case val := <-w1.Changes():
+		c.Fatalf("unexpected value %v", val)
+	case val := <-w2.Changes():
+		c.Assert(val, gc.Equals, "two")
+	case <-time.After(longWait):
+		c.Fatalf("no value received")
+	}
+}
+
+func (s *suite) TestWatcherOfNextContextAndChanges(c *gc.C) {
+	v := NewValueOf(1)
+	w := v.Watch()
+	ok, err := w.NextContext(context.Background())
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(ok, jc.IsTrue)
+	c.Assert(w.Value(), gc.Equals, 1)
+
+	w = v.Watch()
+	changes := w.Changes()
+	select {
+	case val := <-changes:
+		c.Assert(val, gc.Equals, 1)
+	case <-time.After(longWait):
+		c.Fatalf("no value received")
+	}
+	w.Close()
+	for range changes {
+	}
+}
+
+func receive(c *gc.C, ch <-chan interface{}) interface{} {
+	select {
+	case val, ok := <-ch:
+		c.Assert(ok, jc.IsTrue)
+		return val
+	case <-time.After(longWait):
+		c.Fatalf("no value received")
+	}
+	panic("unreachable")
+}
+
+func assertClosed(c *gc.C, ch <-chan interface{}) {
+	select {
+	case val, ok := <-ch:
+		c.Assert(ok, jc.IsFalse, gc.Commentf("received %v", val))
+	case <-time.After(longWait):
+		c.Fatalf("channel not closed")
+	}
+}